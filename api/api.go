@@ -1,19 +1,42 @@
 package api
 
 import (
+	"context"
+
 	"github.com/jadidbourbaki/gofarm/geography"
 )
 
 // FarmersMarketApi is the interface for all the public functions our API
-// will be providing
+// will be providing. Every function takes a context which bounds any requests
+// made to upstream APIs; cancelling it abandons the request.
 type FarmersMarketApi interface {
 	// Refresh the underlying data being used by the API.
-	Refresh() error
-	// NearestN returns the nearest N farmer's markets to a given location.
+	Refresh(ctx context.Context) error
+	// NearestN returns the nearest N farmer's markets to a given location selected by filter.
 	// If n is set to -1 it returns all the farmer's markets in ascending order of distance.
-	NearestN(n int, location geography.HaversinePoint) ([]FarmersMarketRecord, error)
+	// If there are fewer than n, it returns them and adds a warning to the QueryReport of ctx.
+	// If the datasource does not have the data filter needs, it returns ErrUnsupportedFilter.
+	NearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error)
 
-	// NearestNByZipCode returns the nearest N farmers' markets to a given zipcode
+	// NearestNByZipCode returns the nearest N farmers' markets to a given zipcode selected by filter.
 	// If n is set to -1 it returns all the farmer's markets in ascending order of distance.
-	NearestNByZipCode(n int, zipCode string) ([]FarmersMarketRecord, error)
+	NearestNByZipCode(ctx context.Context, n int, zipCode string, filter Filter) ([]FarmersMarketRecord, error)
+
+	// WithinRadius returns the farmers' markets at most distance meters away from a
+	// given location in ascending order of distance.
+	WithinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error)
+
+	// WithinRadiusByZipCode returns the farmers' markets at most distance meters away
+	// from a given zipcode in ascending order of distance.
+	WithinRadiusByZipCode(ctx context.Context, zipCode string, distance float64) ([]FarmersMarketRecord, error)
+
+	// WithinBounds returns the farmers' markets inside the box between the southWest and
+	// northEast corners, which crosses the antimeridian if southWest is east of northEast.
+	// Distances are from the center of the box, and the markets nearest to it come first.
+	// At most limit markets are returned; if limit is set to -1 it returns all of them.
+	WithinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error)
+
+	// Market returns the farmers' market with the given ID, see MarketID. Its Distance is
+	// unknown. If there is no such market, it returns ErrMarketNotFound.
+	Market(ctx context.Context, id string) (FarmersMarketRecord, error)
 }
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// AutoDatasourceName is the name of the datasource choosing another one by location
+const AutoDatasourceName = "auto"
+
+// autoMember is a datasource the auto datasource may choose
+type autoMember struct {
+	datasource Datasource
+	api        FarmersMarketApi
+}
+
+// AutoFarmersMarketApi is the implementation of the FarmersMarketApi that answers each
+// query with the richest datasource covering the location queried, e.g. New York State
+// for a location in New York and the USDA elsewhere. If that datasource fails, the next
+// richest one covering the location is queried. The datasource that answered is
+// recorded in the QueryReport of the context, if it has one.
+type AutoFarmersMarketApi struct {
+	name         string
+	members      []autoMember           // richest first
+	capabilities DatasourceCapabilities // what at least one member has
+	geocoder     Geocoder
+}
+
+func init() {
+	RegisterDatasource(Datasource{
+		Name:        AutoDatasourceName,
+		Title:       "Automatic",
+		Coverage:    "Farmers' Markets in the richest datasource covering the location queried",
+		Attribution: "Uses the data of the other datasources, see their attribution.",
+		// Those of the datasources chosen from once constructed, see Capabilities
+		Capabilities: DatasourceCapabilities{
+			HasSnapData:        true,
+			HasOperationHours:  true,
+			HasOperationSeason: true,
+			HasOperationMonths: true,
+		},
+		Composite: true,
+		New: func(options Options) (FarmersMarketApi, error) {
+			return NewAutoFarmersMarketApi(options)
+		},
+	})
+}
+
+// richness is the number of optional kinds of data a datasource has
+func richness(capabilities DatasourceCapabilities) int {
+	count := 0
+	for _, has := range []bool{capabilities.HasSnapData, capabilities.HasOperationHours, capabilities.HasOperationSeason, capabilities.HasOperationMonths} {
+		if has {
+			count++
+		}
+	}
+
+	return count
+}
+
+// NewAutoFarmersMarketApi returns a pointer to a freshly constructed Farmers Market Api
+// choosing between every datasource served other than the "all" and "auto" ones (see
+// Options.Datasources), those in options.Apis or else constructed with options. The
+// Geocoder must be set in options.
+func NewAutoFarmersMarketApi(options Options) (*AutoFarmersMarketApi, error) {
+	if options.Geocoder == nil {
+		return nil, fmt.Errorf("geocoder not configured")
+	}
+
+	fmApi := &AutoFarmersMarketApi{
+		name:         AutoDatasourceName,
+		capabilities: DatasourceCapabilities{Live: true},
+		geocoder:     options.Geocoder,
+	}
+
+	for _, datasource := range Datasources() {
+		if datasource.Composite || !options.serves(datasource.Name) {
+			continue
+		}
+
+		memberApi, err := options.datasourceApi(datasource)
+		if err != nil {
+			return nil, fmt.Errorf("could not load datasource %s: %w", datasource.Name, err)
+		}
+
+		fmApi.members = append(fmApi.members, autoMember{datasource: datasource, api: memberApi})
+
+		capabilities := datasource.Capabilities
+		fmApi.capabilities.HasSnapData = fmApi.capabilities.HasSnapData || capabilities.HasSnapData
+		fmApi.capabilities.HasOperationHours = fmApi.capabilities.HasOperationHours || capabilities.HasOperationHours
+		fmApi.capabilities.HasOperationSeason = fmApi.capabilities.HasOperationSeason || capabilities.HasOperationSeason
+		fmApi.capabilities.HasOperationMonths = fmApi.capabilities.HasOperationMonths || capabilities.HasOperationMonths
+		fmApi.capabilities.Live = fmApi.capabilities.Live && capabilities.Live
+	}
+
+	if len(fmApi.members) == 0 {
+		return nil, fmt.Errorf("no datasources to choose from")
+	}
+
+	slices.SortStableFunc(fmApi.members, func(a, b autoMember) int {
+		return cmp.Or(
+			cmp.Compare(richness(b.datasource.Capabilities), richness(a.datasource.Capabilities)),
+			strings.Compare(a.datasource.Name, b.datasource.Name),
+		)
+	})
+
+	return fmApi, nil
+}
+
+// Capabilities returns what at least one of the datasources chosen from has. It is
+// only Live if every one of them is.
+func (fmApi *AutoFarmersMarketApi) Capabilities() DatasourceCapabilities {
+	return fmApi.capabilities
+}
+
+// Refresh refreshes every datasource it chooses from. The service refreshes the
+// datasources it serves on their own instead, see Datasource.Composite.
+func (fmApi *AutoFarmersMarketApi) Refresh(ctx context.Context) error {
+	var errs []error
+	for _, member := range fmApi.members {
+		if err := member.api.Refresh(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("refreshing %s: %w", member.datasource.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// membersCovering returns the members covering location, richest first
+func (fmApi *AutoFarmersMarketApi) membersCovering(location geography.HaversinePoint) []autoMember {
+	var members []autoMember
+	for _, member := range fmApi.members {
+		if member.datasource.Area.CoversPoint(location) {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+// membersCoveringZipCode returns the members covering zipCode, richest first. The
+// state of the zip code is enough for members listing the states they cover, the
+// zip code is only geocoded for the others.
+func (fmApi *AutoFarmersMarketApi) membersCoveringZipCode(ctx context.Context, zipCode string) ([]autoMember, error) {
+	state := zipCodeState(zipCode)
+
+	var location *geography.HaversinePoint
+	var members []autoMember
+
+	for _, member := range fmApi.members {
+		area := member.datasource.Area
+
+		if state != "" && area != nil && len(area.States) > 0 {
+			if area.CoversState(state) {
+				members = append(members, member)
+			}
+
+			continue
+		}
+
+		if location == nil && area != nil && len(area.Polygon) > 0 {
+			point, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipCode)
+			if err != nil {
+				return nil, fmt.Errorf("zip code lookup failed: %w", err)
+			}
+
+			location = &point
+		}
+
+		if location == nil || area.CoversPoint(*location) {
+			members = append(members, member)
+		}
+	}
+
+	return members, nil
+}
+
+// query runs query on each member in turn until one succeeds, skipping the members
+// that do not have the data filter needs. It fails if all of them do.
+func (fmApi *AutoFarmersMarketApi) query(ctx context.Context, members []autoMember, filter Filter, query func(member FarmersMarketApi) ([]FarmersMarketRecord, error)) ([]FarmersMarketRecord, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no datasource covers the location")
+	}
+
+	var errs []error
+	for _, member := range members {
+		name := member.datasource.Name
+
+		if err := filter.supportedBy(name, member.datasource.Capabilities); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		records, err := query(member.api)
+		if err == nil {
+			reportDatasource(ctx, name)
+			return records, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+
+		// The next member would not have the time to answer either
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func (fmApi *AutoFarmersMarketApi) NearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error) {
+	return fmApi.query(ctx, fmApi.membersCovering(location), filter, func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.NearestN(ctx, n, location, filter)
+	})
+}
+
+func (fmApi *AutoFarmersMarketApi) NearestNByZipCode(ctx context.Context, n int, zipcode string, filter Filter) ([]FarmersMarketRecord, error) {
+	members, err := fmApi.membersCoveringZipCode(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return fmApi.query(ctx, members, filter, func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.NearestNByZipCode(ctx, n, zipcode, filter)
+	})
+}
+
+func (fmApi *AutoFarmersMarketApi) WithinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error) {
+	return fmApi.query(ctx, fmApi.membersCovering(location), Filter{}, func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.WithinRadius(ctx, location, distance)
+	})
+}
+
+func (fmApi *AutoFarmersMarketApi) WithinRadiusByZipCode(ctx context.Context, zipcode string, distance float64) ([]FarmersMarketRecord, error) {
+	members, err := fmApi.membersCoveringZipCode(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return fmApi.query(ctx, members, Filter{}, func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.WithinRadiusByZipCode(ctx, zipcode, distance)
+	})
+}
+
+// WithinBounds chooses the datasource covering the center of the box
+func (fmApi *AutoFarmersMarketApi) WithinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error) {
+	box, err := geography.NewBoundingBox(southWest, northEast)
+	if err != nil {
+		return nil, fmt.Errorf("within bounds: %w", err)
+	}
+
+	return fmApi.query(ctx, fmApi.membersCovering(box.Center()), Filter{}, func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.WithinBounds(ctx, southWest, northEast, limit)
+	})
+}
+
+// Market looks the market up in the datasource its ID comes from. The ID may be
+// prefixed with the name of the auto datasource, e.g. "auto:usda:312052".
+func (fmApi *AutoFarmersMarketApi) Market(ctx context.Context, id string) (FarmersMarketRecord, error) {
+	datasource, memberID, err := SplitMarketID(id)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	if datasource == fmApi.name {
+		datasource, _, err = SplitMarketID(memberID)
+		if err != nil {
+			return FarmersMarketRecord{}, err
+		}
+	} else {
+		memberID = id
+	}
+
+	for _, member := range fmApi.members {
+		if member.datasource.Name != datasource {
+			continue
+		}
+
+		record, err := member.api.Market(ctx, memberID)
+		if err != nil {
+			return FarmersMarketRecord{}, err
+		}
+
+		reportDatasource(ctx, datasource)
+		return record, nil
+	}
+
+	return FarmersMarketRecord{}, fmt.Errorf("%w: %s", ErrMarketNotFound, id)
+}
+
+// Verify that AutoFarmersMarketApi implements FarmersMarketApi
+var _ FarmersMarketApi = (*AutoFarmersMarketApi)(nil)
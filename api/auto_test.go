@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+// boston is outside of New York State, where only the USDA covers
+var boston = geography.HaversinePoint{Latitude: 42.36, Longitude: -71.06}
+
+// newTestAutoApi returns the "auto" datasource over New York and USDA stand-ins
+// responding with the given bodies, or failing if the body is empty
+func newTestAutoApi(t *testing.T, newYorkResponse string, usdaResponse string) *AutoFarmersMarketApi {
+	upstream := func(body string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body == "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	// Failures are simulated, retrying them would only slow the tests down
+	newYork := NewNewYorkClient(nil)
+	newYork.BaseURL = upstream(newYorkResponse)
+
+	usda := NewUSDAClient("key", nil)
+	usda.BaseURL = upstream(usdaResponse)
+
+	fmApi, err := NewAutoFarmersMarketApi(Options{USDA: usda, NewYork: newYork, Geocoder: GeocoderChain{}})
+	require.Nil(t, err)
+
+	return fmApi
+}
+
+func TestAutoNearestN(t *testing.T) {
+	fmApi := newTestAutoApi(t, mergedTestNewYorkResponse, mergedTestUSDAResponse)
+
+	// New York State has more data than the USDA, so it answers in New York
+	ctx, report := WithQueryReport(context.Background())
+	records, err := fmApi.NearestN(ctx, -1, albany, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Equal(t, "newyork", report.Datasource())
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Albany County Farmers' Market", records[0].Name)
+
+	ctx, report = WithQueryReport(context.Background())
+	records, err = fmApi.NearestN(ctx, -1, boston, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, "usda", report.Datasource())
+	require.Equal(t, 3, len(records))
+
+	// The USDA does not know about SNAP
+	_, err = fmApi.NearestN(context.Background(), -1, boston, Filter{SnapStatus: true})
+	require.ErrorIs(t, err, ErrUnsupportedFilter)
+
+	ctx, report = WithQueryReport(context.Background())
+	record, err := fmApi.Market(ctx, "auto:usda:2@42.65310_-73.76410")
+	require.Nil(t, err)
+	require.Equal(t, "Lark Street Market", record.Name)
+	require.Equal(t, "usda", report.Datasource())
+}
+
+func TestAutoNearestNFallsBack(t *testing.T) {
+	// The USDA answers when New York State fails
+	fmApi := newTestAutoApi(t, "", mergedTestUSDAResponse)
+
+	ctx, report := WithQueryReport(context.Background())
+	records, err := fmApi.NearestN(ctx, -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, "usda", report.Datasource())
+	require.Equal(t, 3, len(records))
+
+	fmApi = newTestAutoApi(t, "", "")
+
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.NotNil(t, err)
+}
+
+func TestAutoMembersCoveringZipCode(t *testing.T) {
+	fmApi := newTestAutoApi(t, mergedTestNewYorkResponse, mergedTestUSDAResponse)
+
+	names := func(members []autoMember) []string {
+		var names []string
+		for _, member := range members {
+			names = append(names, member.datasource.Name)
+		}
+
+		return names
+	}
+
+	// The state of the zip code is enough, the geocoder is not needed
+	members, err := fmApi.membersCoveringZipCode(context.Background(), "12207")
+	require.Nil(t, err)
+	require.Equal(t, []string{"newyork", "usda"}, names(members))
+
+	members, err = fmApi.membersCoveringZipCode(context.Background(), "02108")
+	require.Nil(t, err)
+	require.Equal(t, []string{"usda"}, names(members))
+}
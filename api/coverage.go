@@ -0,0 +1,82 @@
+package api
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// CoverageArea is the area a datasource has markets in, as the US states it covers
+// and an outline of them. A nil CoverageArea covers everywhere.
+type CoverageArea struct {
+	States  []string          `json:"states,omitempty"`  // two letter codes, e.g. "NY"
+	Polygon geography.Polygon `json:"polygon,omitempty"` // outline of the area, may be approximate
+}
+
+// CoversPoint returns true if the area covers p. Only the polygon can tell, an area
+// without one covers every point.
+func (area *CoverageArea) CoversPoint(p geography.HaversinePoint) bool {
+	if area == nil || len(area.Polygon) == 0 {
+		return true
+	}
+
+	return area.Polygon.Contains(p)
+}
+
+// CoversState returns true if the area covers the US state with the given two
+// letter code. Only the states can tell, an area without them covers every state.
+func (area *CoverageArea) CoversState(state string) bool {
+	if area == nil || len(area.States) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(area.States, func(covered string) bool {
+		return strings.EqualFold(covered, state)
+	})
+}
+
+// zipCodePrefixStates maps ranges of the first three digits of zip codes to the
+// state they are in. The ranges are checked in order, so the few prefixes that
+// are an exception within a larger range come first.
+var zipCodePrefixStates = []struct {
+	first, last int
+	state       string
+}{
+	{5, 5, "NY"}, {55, 55, "MA"}, {201, 201, "VA"}, {569, 569, "DC"}, {733, 733, "TX"}, {885, 885, "TX"},
+	{6, 7, "PR"}, {8, 8, "VI"}, {9, 9, "PR"},
+	{10, 27, "MA"}, {28, 29, "RI"}, {30, 38, "NH"}, {39, 49, "ME"}, {50, 59, "VT"},
+	{60, 69, "CT"}, {70, 89, "NJ"}, {100, 149, "NY"}, {150, 196, "PA"}, {197, 199, "DE"},
+	{200, 205, "DC"}, {206, 219, "MD"}, {220, 246, "VA"}, {247, 268, "WV"}, {270, 289, "NC"},
+	{290, 299, "SC"}, {300, 319, "GA"}, {320, 349, "FL"}, {350, 369, "AL"}, {370, 385, "TN"},
+	{386, 397, "MS"}, {398, 399, "GA"}, {400, 427, "KY"}, {430, 459, "OH"}, {460, 479, "IN"},
+	{480, 499, "MI"}, {500, 528, "IA"}, {530, 549, "WI"}, {550, 567, "MN"}, {570, 577, "SD"},
+	{580, 588, "ND"}, {590, 599, "MT"}, {600, 629, "IL"}, {630, 658, "MO"}, {660, 679, "KS"},
+	{680, 693, "NE"}, {700, 715, "LA"}, {716, 729, "AR"}, {730, 749, "OK"}, {750, 799, "TX"},
+	{800, 816, "CO"}, {820, 831, "WY"}, {832, 838, "ID"}, {840, 847, "UT"}, {850, 865, "AZ"},
+	{870, 884, "NM"}, {889, 898, "NV"}, {900, 961, "CA"}, {967, 968, "HI"}, {969, 969, "GU"},
+	{970, 979, "OR"}, {980, 994, "WA"}, {995, 999, "AK"},
+}
+
+// zipCodeState returns the two letter code of the state a zip code is in, from its
+// first three digits, or an empty string if it is not known
+func zipCodeState(zipCode string) string {
+	zipCode = strings.TrimSpace(zipCode)
+	if len(zipCode) < 5 {
+		return ""
+	}
+
+	prefix, err := strconv.Atoi(zipCode[:3])
+	if err != nil || prefix < 0 {
+		return ""
+	}
+
+	for _, states := range zipCodePrefixStates {
+		if states.first <= prefix && prefix <= states.last {
+			return states.state
+		}
+	}
+
+	return ""
+}
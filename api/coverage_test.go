@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewYorkArea(t *testing.T) {
+	for _, inside := range []geography.HaversinePoint{
+		albany,
+		{Latitude: 40.71, Longitude: -74.00}, // Manhattan
+		{Latitude: 44.67, Longitude: -74.98}, // Potsdam
+		{Latitude: 42.89, Longitude: -78.88}, // Buffalo
+		{Latitude: 40.79, Longitude: -73.12}, // Long Island
+	} {
+		require.True(t, newYorkArea.CoversPoint(inside), "%v", inside)
+	}
+
+	for _, outside := range []geography.HaversinePoint{
+		{Latitude: 40.72, Longitude: -74.07}, // Jersey City
+		{Latitude: 39.95, Longitude: -75.16}, // Philadelphia
+		{Latitude: 42.36, Longitude: -71.06}, // Boston
+		{Latitude: 44.48, Longitude: -73.21}, // Burlington, Vermont
+		{Latitude: 43.65, Longitude: -79.38}, // Toronto
+		{Latitude: 41.31, Longitude: -72.92}, // New Haven
+	} {
+		require.False(t, newYorkArea.CoversPoint(outside), "%v", outside)
+	}
+
+	require.True(t, newYorkArea.CoversState("ny"))
+	require.False(t, newYorkArea.CoversState("NJ"))
+
+	var everywhere *CoverageArea
+	require.True(t, everywhere.CoversPoint(geography.HaversinePoint{}))
+	require.True(t, everywhere.CoversState("HI"))
+}
+
+func TestZipCodeState(t *testing.T) {
+	require.Equal(t, "NY", zipCodeState("13676"))
+	require.Equal(t, "NY", zipCodeState("00501"))
+	require.Equal(t, "NY", zipCodeState("10024-1234"))
+	require.Equal(t, "MA", zipCodeState("02108"))
+	require.Equal(t, "MA", zipCodeState("05501"))
+	require.Equal(t, "VT", zipCodeState("05401"))
+	require.Equal(t, "TX", zipCodeState("73301"))
+	require.Equal(t, "OK", zipCodeState("73102"))
+	require.Equal(t, "CA", zipCodeState("94103"))
+
+	require.Equal(t, "", zipCodeState("123"))
+	require.Equal(t, "", zipCodeState("abcde"))
+	require.Equal(t, "", zipCodeState("09001"))
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"golang.org/x/sync/singleflight"
+)
+
+// coldLoadTimeout bounds the first load of a cachedDataset. The first load is shared
+// by every request waiting for it, so it is not cancelled when any single one of
+// them gives up.
+const coldLoadTimeout = 2 * time.Minute
+
+// datasetSnapshot is an immutable copy of a dataset. Neither the snapshot nor the
+// records in it may be modified once it has been published by a cachedDataset.
+type datasetSnapshot struct {
+	records  []FarmersMarketRecord
+	index    *geography.VantagePointTree // over the locations of records, in the same order
+	byID     map[string]int              // index of the record with each ID
+	loadedAt time.Time
+}
+
+// cachedDataset holds a dataset that is downloaded in its entirety and then queried
+// locally, such as the New York State dataset. Refreshes build a new snapshot and
+// swap it in atomically, so queries never observe a partially loaded dataset and
+// keep being served from the previous snapshot while a refresh is in flight.
+type cachedDataset struct {
+	// load downloads and converts the whole dataset
+	load        func(ctx context.Context) ([]FarmersMarketRecord, error)
+	metricSpace geography.MetricSpace
+
+	snapshot  atomic.Pointer[datasetSnapshot]
+	coldLoads singleflight.Group
+}
+
+// newCachedDataset returns a cachedDataset that has not been loaded yet
+func newCachedDataset(load func(ctx context.Context) ([]FarmersMarketRecord, error)) *cachedDataset {
+	return &cachedDataset{
+		load:        load,
+		metricSpace: geography.DefaultHaversineMetricSpace,
+	}
+}
+
+// refresh loads the dataset and publishes it as the current snapshot. If loading
+// fails, the previous snapshot (if any) stays current.
+func (dataset *cachedDataset) refresh(ctx context.Context) error {
+	records, err := dataset.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	points := make([]geography.Point, len(records))
+	for idx := range records {
+		points[idx] = records[idx].HaversinePoint()
+	}
+
+	// Building the index here keeps the cost of it off the requests
+	index, err := geography.NewVantagePointTree(dataset.metricSpace, points)
+	if err != nil {
+		return fmt.Errorf("indexing dataset: %w", err)
+	}
+
+	// Should two records share an ID, the first one wins
+	byID := make(map[string]int, len(records))
+	for idx := len(records) - 1; idx >= 0; idx-- {
+		byID[records[idx].ID] = idx
+	}
+
+	dataset.snapshot.Store(&datasetSnapshot{records: records, index: index, byID: byID, loadedAt: time.Now()})
+
+	return nil
+}
+
+// current returns the current snapshot, loading the dataset first if it has never
+// been loaded. Concurrent callers arriving before the first load has finished
+// share a single load. When the snapshot was loaded is added to the QueryReport
+// of ctx.
+func (dataset *cachedDataset) current(ctx context.Context) (*datasetSnapshot, error) {
+	if snapshot := dataset.snapshot.Load(); snapshot != nil {
+		reportDataAsOf(ctx, snapshot.loadedAt)
+		return snapshot, nil
+	}
+
+	result := dataset.coldLoads.DoChan("load", func() (interface{}, error) {
+		// Someone may have finished loading between our check above and us getting here
+		if snapshot := dataset.snapshot.Load(); snapshot != nil {
+			return snapshot, nil
+		}
+
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), coldLoadTimeout)
+		defer cancel()
+
+		if err := dataset.refresh(loadCtx); err != nil {
+			return nil, err
+		}
+
+		return dataset.snapshot.Load(), nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case loaded := <-result:
+		if loaded.Err != nil {
+			return nil, loaded.Err
+		}
+
+		snapshot := loaded.Val.(*datasetSnapshot)
+		reportDataAsOf(ctx, snapshot.loadedAt)
+
+		return snapshot, nil
+	}
+}
+
+// recordsFor returns private copies of the records of the snapshot found by a query
+// on its index, with their Distance set to the distance the query found
+func (snapshot *datasetSnapshot) recordsFor(neighbors []geography.Neighbor) []FarmersMarketRecord {
+	records := make([]FarmersMarketRecord, len(neighbors))
+	for idx, neighbor := range neighbors {
+		records[idx] = snapshot.records[neighbor.Index]
+		records[idx].Distance = neighbor.Distance
+		records[idx].DistanceUnits = geography.Meters
+	}
+
+	return records
+}
+
+// nearestN implements FarmersMarketApi.NearestN for the dataset
+func (dataset *cachedDataset) nearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error) {
+	snapshot, err := dataset.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	all := n == -1
+	if all {
+		n = len(snapshot.records)
+	}
+
+	// The only negative value we accept is -1, which is handled above
+	if n < 0 {
+		return nil, fmt.Errorf("invalid value for n")
+	}
+
+	var match func(index int) bool
+	if !filter.IsEmpty() {
+		match = func(index int) bool { return filter.Matches(snapshot.records[index]) }
+	}
+
+	neighbors, err := snapshot.index.NearestMatching(location, n, match)
+	if err != nil {
+		return nil, fmt.Errorf("nearest n: %w", err)
+	}
+
+	records := snapshot.recordsFor(neighbors)
+
+	if !all && n > len(records) {
+		reportWarning(ctx, "found %d of the %d markets requested", len(records), n)
+	}
+
+	return records, nil
+}
+
+// withinRadius implements FarmersMarketApi.WithinRadius for the dataset
+func (dataset *cachedDataset) withinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error) {
+	if distance < 0 || math.IsNaN(distance) {
+		return nil, fmt.Errorf("invalid value for distance")
+	}
+
+	snapshot, err := dataset.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors, err := snapshot.index.WithinRadius(location, distance)
+	if err != nil {
+		return nil, fmt.Errorf("within radius: %w", err)
+	}
+
+	return snapshot.recordsFor(neighbors), nil
+}
+
+// withinBounds implements FarmersMarketApi.WithinBounds for the dataset. The index
+// finds the markets within the circle around the box, which are then narrowed down
+// to the ones inside the box.
+func (dataset *cachedDataset) withinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error) {
+	box, err := geography.NewBoundingBox(southWest, northEast)
+	if err != nil {
+		return nil, fmt.Errorf("within bounds: %w", err)
+	}
+
+	snapshot, err := dataset.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors, err := snapshot.index.WithinRadius(box.Center(), box.Radius())
+	if err != nil {
+		return nil, fmt.Errorf("within bounds: %w", err)
+	}
+
+	var inside []geography.Neighbor
+	for _, neighbor := range neighbors {
+		if box.Contains(snapshot.records[neighbor.Index].HaversinePoint()) {
+			inside = append(inside, neighbor)
+		}
+	}
+
+	return limitRecords(snapshot.recordsFor(inside), limit)
+}
+
+// market implements FarmersMarketApi.Market for the dataset
+func (dataset *cachedDataset) market(ctx context.Context, id string) (FarmersMarketRecord, error) {
+	snapshot, err := dataset.current(ctx)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	idx, ok := snapshot.byID[id]
+	if !ok {
+		return FarmersMarketRecord{}, fmt.Errorf("%w: %s", ErrMarketNotFound, id)
+	}
+
+	record := snapshot.records[idx]
+	record.Distance = -1
+	record.DistanceUnits = ""
+
+	return record, nil
+}
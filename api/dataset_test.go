@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDatasetRecords = []FarmersMarketRecord{
+	{Name: "Albany County Farmers' Market", Location: geography.HaversinePoint{Latitude: 42.64819, Longitude: -73.75383}, Distance: -1},
+	{Name: "82nd Street Greenmarket", Location: geography.HaversinePoint{Latitude: 40.77394, Longitude: -73.9506}, Distance: -1},
+}
+
+var albany = geography.HaversinePoint{Latitude: 42.65, Longitude: -73.75}
+var upperEastSide = geography.HaversinePoint{Latitude: 40.78, Longitude: -73.95}
+
+func TestCachedDatasetColdLoadIsShared(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+
+	dataset := newCachedDataset(func(ctx context.Context) ([]FarmersMarketRecord, error) {
+		loads.Add(1)
+		<-release
+		return testDatasetRecords, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		location := albany
+		expectedNearest := "Albany County Farmers' Market"
+		if i%2 == 0 {
+			location = upperEastSide
+			expectedNearest = "82nd Street Greenmarket"
+		}
+
+		go func() {
+			defer wg.Done()
+
+			records, err := dataset.nearestN(context.Background(), 1, location, Filter{})
+			if assert.Nil(t, err) {
+				assert.Equal(t, expectedNearest, records[0].Name)
+			}
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), loads.Load())
+
+	// The shared snapshot was not modified by any of the queries
+	for _, record := range dataset.snapshot.Load().records {
+		require.Equal(t, -1.0, record.Distance)
+	}
+}
+
+func TestCachedDatasetKeepsSnapshotWhenRefreshFails(t *testing.T) {
+	fail := false
+
+	dataset := newCachedDataset(func(ctx context.Context) ([]FarmersMarketRecord, error) {
+		if fail {
+			return nil, errors.New("upstream failed")
+		}
+
+		return testDatasetRecords, nil
+	})
+
+	require.Nil(t, dataset.refresh(context.Background()))
+
+	fail = true
+	require.NotNil(t, dataset.refresh(context.Background()))
+
+	records, err := dataset.nearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+}
+
+func TestCachedDatasetWaiterCanGiveUp(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	dataset := newCachedDataset(func(ctx context.Context) ([]FarmersMarketRecord, error) {
+		<-release
+		return testDatasetRecords, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := dataset.nearestN(ctx, 1, albany, Filter{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCachedDatasetWithinBounds(t *testing.T) {
+	records := append(slices.Clone(testDatasetRecords),
+		FarmersMarketRecord{Name: "Adak", Location: geography.HaversinePoint{Latitude: 51.88, Longitude: -176.66}, Distance: -1},
+		FarmersMarketRecord{Name: "Attu", Location: geography.HaversinePoint{Latitude: 52.9, Longitude: 173.1}, Distance: -1},
+	)
+
+	dataset := newCachedDataset(func(ctx context.Context) ([]FarmersMarketRecord, error) {
+		return records, nil
+	})
+
+	ctx := context.Background()
+
+	// A box around New York State
+	found, err := dataset.withinBounds(ctx, geography.HaversinePoint{Latitude: 40.5, Longitude: -79.8}, geography.HaversinePoint{Latitude: 45, Longitude: -71.8}, -1)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(found))
+
+	// A box across the antimeridian around the Aleutians, nearest to its center first
+	aleutiansSouthWest := geography.HaversinePoint{Latitude: 51, Longitude: 172}
+	aleutiansNorthEast := geography.HaversinePoint{Latitude: 55, Longitude: -172}
+
+	found, err = dataset.withinBounds(ctx, aleutiansSouthWest, aleutiansNorthEast, -1)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(found))
+	require.Equal(t, "Adak", found[0].Name)
+	require.Equal(t, "Attu", found[1].Name)
+
+	found, err = dataset.withinBounds(ctx, aleutiansSouthWest, aleutiansNorthEast, 1)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+
+	_, err = dataset.withinBounds(ctx, aleutiansNorthEast, aleutiansSouthWest, -1)
+	require.NotNil(t, err)
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"time"
+
 	"github.com/jadidbourbaki/gofarm/geography"
 )
 
@@ -12,20 +14,54 @@ type FarmersMarketAddress struct {
 	ZipCode string
 }
 
+// FarmersMarketContact is who to get in touch with about the Farmers' Market
+type FarmersMarketContact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+}
+
+// FarmersMarketSocialMedia are links to the Farmers' Market's online presence besides its Website
+type FarmersMarketSocialMedia struct {
+	Facebook  string `json:"facebook,omitempty"`
+	Instagram string `json:"instagram,omitempty"`
+	Twitter   string `json:"twitter,omitempty"`
+	Pinterest string `json:"pinterest,omitempty"`
+	Youtube   string `json:"youtube,omitempty"`
+	Blog      string `json:"blog,omitempty"`
+}
+
 // FarmersMarketRecord is the default structure for storing information about Farmers'
 // Market entries from various APIs. this should contain all the relevant information
 // that is common amongst most Farmers' Market datasets. Aside from the Name,
 // the Address, the Distance, and the Location, everything else is optional.
+//
+// Note on Distance:
+// Every datasource computes the Distance from the queried location itself with the
+// geography.HaversineMetricSpace, whatever its upstream API reports, so Distance is
+// always in meters (the canonical unit) when returned by a FarmersMarketApi.
+// ConvertDistances converts it into another unit and records that in DistanceUnits.
 type FarmersMarketRecord struct {
-	Name                          string                   `json:"name"`
-	Description                   string                   `json:"description,omitempty"`
-	Address                       FarmersMarketAddress     `json:"address"`
-	Distance                      float64                  `json:"distance"`
-	Website                       string                   `json:"website,omitempty"`
-	Location                      geography.HaversinePoint `json:"location"`
-	OperationHours                string                   `json:"operation_hours,omitempty"`       // Day and Hours the market is open
-	OperationSeason               string                   `json:"operation_season,omitempty"`      // Month and day when the market opens and closes for the year
-	OperationMonthsCode           string                   `json:"operation_months_code,omitempty"` // See "Note on Operation Months Code" in NewYorkFarmersMarketRecord
-	FarmersMarketNutritionProgram bool                     `json:"fmnp,omitempty"`                  // true indicates that this market is part of the Farmers Market Nutrition Program.
-	SnapStatus                    bool                     `json:"snap_status,omitempty"`           // true indicates the market accepts SNAP; www.snaptomarket.com
+	ID                            string                    `json:"id"` // See MarketID, stable across refreshes
+	Name                          string                    `json:"name"`
+	Description                   string                    `json:"description,omitempty"`
+	Address                       FarmersMarketAddress      `json:"address"`
+	Distance                      float64                   `json:"distance"`                 // See "Note on Distance" above, -1 if unknown
+	DistanceUnits                 geography.DistanceUnit    `json:"distance_units,omitempty"` // Unit of Distance
+	Website                       string                    `json:"website,omitempty"`
+	Contact                       *FarmersMarketContact     `json:"contact,omitempty"`      // nil if the datasource has no contact
+	SocialMedia                   *FarmersMarketSocialMedia `json:"social_media,omitempty"` // nil if the datasource has no social media links
+	ImageURL                      string                    `json:"image_url,omitempty"`
+	LastUpdated                   *time.Time                `json:"last_updated,omitempty"` // When the datasource last updated the listing, nil if unknown
+	Location                      geography.HaversinePoint  `json:"location"`
+	OperationHours                string                    `json:"operation_hours,omitempty"`       // Day and Hours the market is open
+	OperationSchedule             WeeklySchedule            `json:"operation_schedule,omitempty"`    // OperationHours parsed by ParseOperationHours, nil if it could not be parsed
+	OperationSeason               string                    `json:"operation_season,omitempty"`      // Month and day when the market opens and closes for the year
+	SeasonStart                   *MonthDay                 `json:"season_start,omitempty"`          // OperationSeason parsed by ParseOperationSeason, nil if it could not be parsed
+	SeasonEnd                     *MonthDay                 `json:"season_end,omitempty"`            // See SeasonStart
+	OperationMonthsCode           string                    `json:"operation_months_code,omitempty"` // The New York State Operation Months Code, see Season
+	Seasons                       SeasonSet                 `json:"seasons,omitempty"`               // Seasons the market operates in, e.g. OperationMonthsCode decoded
+	FarmersMarketNutritionProgram bool                      `json:"fmnp,omitempty"`                  // true indicates that this market is part of the Farmers Market Nutrition Program.
+	SnapStatus                    bool                      `json:"snap_status,omitempty"`           // true indicates the market accepts SNAP; www.snaptomarket.com
+	MergedFrom                    []string                  `json:"merged_from,omitempty"`           // IDs of the records merged into this one, see MergedFarmersMarketApi
 }
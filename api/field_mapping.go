@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// FieldMapping names the column of a dataset each field of a FarmersMarketRecord is
+// read from. Only Name, Latitude and Longitude are required, fields without a column
+// are left empty. In JSON datasets, the columns of nested objects are named by their
+// path, e.g. "market_link.url".
+type FieldMapping struct {
+	ID          string `json:"id,omitempty"` // stable ID of the market within the dataset, see MarketID
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Street      string `json:"street,omitempty"`
+	City        string `json:"city,omitempty"`
+	State       string `json:"state,omitempty"`
+	ZipCode     string `json:"zip_code,omitempty"`
+	Latitude    string `json:"latitude"`
+	Longitude   string `json:"longitude"`
+	Website     string `json:"website,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+
+	ContactName  string `json:"contact_name,omitempty"`
+	ContactEmail string `json:"contact_email,omitempty"`
+	ContactPhone string `json:"contact_phone,omitempty"`
+
+	OperationHours      string `json:"operation_hours,omitempty"`       // free text, see ParseOperationHours
+	OperationSeason     string `json:"operation_season,omitempty"`      // free text, see ParseOperationSeason
+	OperationMonthsCode string `json:"operation_months_code,omitempty"` // see ParseOperationMonthsCode
+
+	// Yes or no columns, see parseMappedBool
+	SnapStatus                    string `json:"snap_status,omitempty"`
+	FarmersMarketNutritionProgram string `json:"fmnp,omitempty"`
+}
+
+// validate returns an error if a required column is missing
+func (mapping FieldMapping) validate() error {
+	if mapping.Name == "" || mapping.Latitude == "" || mapping.Longitude == "" {
+		return fmt.Errorf("field mapping needs the name, latitude and longitude columns")
+	}
+
+	return nil
+}
+
+// Capabilities returns the capabilities of a datasource whose records are read with
+// the mapping. It is never Live.
+func (mapping FieldMapping) Capabilities() DatasourceCapabilities {
+	return DatasourceCapabilities{
+		HasSnapData:        mapping.SnapStatus != "" || mapping.FarmersMarketNutritionProgram != "",
+		HasOperationHours:  mapping.OperationHours != "",
+		HasOperationSeason: mapping.OperationSeason != "",
+		HasOperationMonths: mapping.OperationMonthsCode != "" || mapping.OperationSeason != "",
+	}
+}
+
+// parseMappedBool returns true for the values datasets use to say yes, such as "Y",
+// "Yes", "true", "1" or "X"
+func parseMappedBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "y", "yes", "true", "t", "1", "x":
+		return true
+	}
+
+	return false
+}
+
+// Record converts a row of a dataset, keyed by column, into a FarmersMarketRecord of
+// the given datasource. Hours and seasons are parsed like those of New York State.
+func (mapping FieldMapping) Record(datasource string, row map[string]string) (FarmersMarketRecord, error) {
+	column := func(name string) string {
+		if name == "" {
+			return ""
+		}
+
+		return strings.TrimSpace(row[name])
+	}
+
+	name := column(mapping.Name)
+	if name == "" {
+		return FarmersMarketRecord{}, fmt.Errorf("market without a name in column %q", mapping.Name)
+	}
+
+	latitude, err := strconv.ParseFloat(column(mapping.Latitude), 64)
+	if err != nil {
+		return FarmersMarketRecord{}, fmt.Errorf("could not parse latitude of %s: %w", name, err)
+	}
+
+	longitude, err := strconv.ParseFloat(column(mapping.Longitude), 64)
+	if err != nil {
+		return FarmersMarketRecord{}, fmt.Errorf("could not parse longitude of %s: %w", name, err)
+	}
+
+	location := geography.HaversinePoint{Latitude: latitude, Longitude: longitude}
+
+	id := hashedMarketID(datasource, name, location)
+	if localID := column(mapping.ID); localID != "" {
+		id = MarketID(datasource, localID)
+	}
+
+	// Hours and seasons we cannot make sense of are still shown as written
+	operationHours := column(mapping.OperationHours)
+	schedule, _ := ParseOperationHours(operationHours)
+
+	operationSeason := column(mapping.OperationSeason)
+
+	var seasonStart, seasonEnd *MonthDay
+	if start, end, err := ParseOperationSeason(operationSeason); err == nil {
+		seasonStart, seasonEnd = &start, &end
+	}
+
+	operationMonthsCode := column(mapping.OperationMonthsCode)
+
+	seasons, _ := ParseOperationMonthsCode(operationMonthsCode)
+	if seasons == 0 && seasonStart != nil {
+		seasons = SeasonsBetween(*seasonStart, *seasonEnd)
+	}
+
+	contact := &FarmersMarketContact{
+		Name:  column(mapping.ContactName),
+		Email: column(mapping.ContactEmail),
+		Phone: column(mapping.ContactPhone),
+	}
+	if *contact == (FarmersMarketContact{}) {
+		contact = nil
+	}
+
+	return FarmersMarketRecord{
+		ID:          id,
+		Name:        name,
+		Description: column(mapping.Description),
+		Address: FarmersMarketAddress{
+			Street:  column(mapping.Street),
+			City:    column(mapping.City),
+			State:   column(mapping.State),
+			ZipCode: column(mapping.ZipCode),
+		},
+		Location:                      location,
+		Website:                       column(mapping.Website),
+		ImageURL:                      column(mapping.ImageURL),
+		Contact:                       contact,
+		OperationHours:                operationHours,
+		OperationSchedule:             schedule,
+		OperationSeason:               operationSeason,
+		SeasonStart:                   seasonStart,
+		SeasonEnd:                     seasonEnd,
+		OperationMonthsCode:           operationMonthsCode,
+		Seasons:                       seasons,
+		FarmersMarketNutritionProgram: parseMappedBool(column(mapping.FarmersMarketNutritionProgram)),
+		SnapStatus:                    parseMappedBool(column(mapping.SnapStatus)),
+
+		// We do not have a distance value so we will use -1 as the default
+		Distance: -1,
+	}, nil
+}
+
+// maxSkippedRowWarnings is the number of skipped rows Records reports one by one
+const maxSkippedRowWarnings = 10
+
+// Records converts the rows of a dataset into FarmersMarketRecords of the given
+// datasource, see Record. Rows that cannot be converted, e.g. without a name or with
+// a malformed location, are skipped and reported as warnings in the QueryReport of
+// ctx, so that a single bad row does not take the whole datasource offline. It only
+// fails if none of the rows can be converted.
+func (mapping FieldMapping) Records(ctx context.Context, datasource string, rows []map[string]string) ([]FarmersMarketRecord, error) {
+	records := make([]FarmersMarketRecord, 0, len(rows))
+
+	var errs []error
+	for idx, row := range rows {
+		record, err := mapping.Record(datasource, row)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("row %d: %w", idx+1, err))
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for idx, err := range errs {
+		if idx == maxSkippedRowWarnings {
+			reportWarning(ctx, "%s: skipped %d more rows", datasource, len(errs)-idx)
+			break
+		}
+
+		reportWarning(ctx, "%s: skipped %v", datasource, err)
+	}
+
+	return records, nil
+}
+
+// jsonRow flattens a JSON object into a row keyed by column. Nested objects are
+// keyed by their path, e.g. "market_link.url", values other than strings by their
+// JSON text, and null values are left out.
+func jsonRow(object map[string]json.RawMessage) map[string]string {
+	row := make(map[string]string, len(object))
+
+	var flatten func(prefix string, object map[string]json.RawMessage)
+	flatten = func(prefix string, object map[string]json.RawMessage) {
+		for key, raw := range object {
+			if string(raw) == "null" {
+				continue
+			}
+
+			var nested map[string]json.RawMessage
+			if json.Unmarshal(raw, &nested) == nil {
+				flatten(prefix+key+".", nested)
+				continue
+			}
+
+			var text string
+			if json.Unmarshal(raw, &text) == nil {
+				row[prefix+key] = text
+				continue
+			}
+
+			row[prefix+key] = string(raw)
+		}
+	}
+
+	flatten("", object)
+
+	return row
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+// testFieldMapping reads the columns of a market association spreadsheet
+var testFieldMapping = FieldMapping{
+	ID:              "Market ID",
+	Name:            "Market",
+	Street:          "Address",
+	City:            "Town",
+	State:           "State",
+	Latitude:        "Lat",
+	Longitude:       "Lon",
+	ContactPhone:    "Phone",
+	OperationHours:  "Hours",
+	OperationSeason: "Season",
+	SnapStatus:      "SNAP?",
+	ZipCode:         "Zip",
+	Website:         "Web",
+}
+
+func TestFieldMappingRecord(t *testing.T) {
+	record, err := testFieldMapping.Record("association", map[string]string{
+		"Market ID": "7",
+		"Market":    " Pearl Street Market ",
+		"Address":   "51 S. Pearl St",
+		"Town":      "Albany",
+		"State":     "NY",
+		"Lat":       "42.64819",
+		"Lon":       "-73.75383",
+		"Phone":     "5184652143",
+		"Hours":     "Sun 10am-2pm",
+		"Season":    "July 14-September 29",
+		"SNAP?":     "Yes",
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, "association:7", record.ID)
+	require.Equal(t, "Pearl Street Market", record.Name)
+	require.Equal(t, FarmersMarketAddress{Street: "51 S. Pearl St", City: "Albany", State: "NY"}, record.Address)
+	require.Equal(t, geography.HaversinePoint{Latitude: 42.64819, Longitude: -73.75383}, record.Location)
+	require.Equal(t, &FarmersMarketContact{Phone: "5184652143"}, record.Contact)
+	require.Equal(t, WeeklySchedule{{Day: Weekday(time.Sunday), Opens: 10 * 60, Closes: 14 * 60}}, record.OperationSchedule)
+	require.Equal(t, &MonthDay{Month: time.July, Day: 14}, record.SeasonStart)
+	require.Equal(t, NewSeasonSet(Summer), record.Seasons)
+	require.True(t, record.SnapStatus)
+	require.False(t, record.FarmersMarketNutritionProgram)
+	require.Equal(t, -1.0, record.Distance)
+
+	// Markets without an ID of their own get a hashed one
+	record, err = testFieldMapping.Record("association", map[string]string{"Market": "Pearl Street Market", "Lat": "42.64819", "Lon": "-73.75383"})
+	require.Nil(t, err)
+	require.Equal(t, hashedMarketID("association", "Pearl Street Market", record.Location), record.ID)
+	require.Nil(t, record.Contact)
+
+	_, err = testFieldMapping.Record("association", map[string]string{"Market": "Pearl Street Market", "Lat": "north", "Lon": "-73.75383"})
+	require.NotNil(t, err)
+
+	_, err = testFieldMapping.Record("association", map[string]string{"Lat": "42.64819", "Lon": "-73.75383"})
+	require.NotNil(t, err)
+}
+
+func TestFieldMappingCapabilities(t *testing.T) {
+	require.Equal(t, DatasourceCapabilities{HasSnapData: true, HasOperationHours: true, HasOperationSeason: true, HasOperationMonths: true}, testFieldMapping.Capabilities())
+	require.Equal(t, DatasourceCapabilities{}, FieldMapping{Name: "name", Latitude: "lat", Longitude: "lon"}.Capabilities())
+
+	// Filtering on the season only needs the season, and on the hours only the hours
+	seasonOnly := FieldMapping{Name: "name", Latitude: "lat", Longitude: "lon", OperationSeason: "season"}.Capabilities()
+	require.Nil(t, Filter{InSeasonOn: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)}.supportedBy("association", seasonOnly))
+	require.ErrorIs(t, Filter{OpenAt: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}.supportedBy("association", seasonOnly), ErrUnsupportedFilter)
+
+	hoursOnly := FieldMapping{Name: "name", Latitude: "lat", Longitude: "lon", OperationHours: "hours"}.Capabilities()
+	require.Nil(t, Filter{OpenAt: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)}.supportedBy("association", hoursOnly))
+	require.ErrorIs(t, Filter{InSeasonOn: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)}.supportedBy("association", hoursOnly), ErrUnsupportedFilter)
+
+	require.Nil(t, testFieldMapping.validate())
+	require.NotNil(t, FieldMapping{Name: "name", Latitude: "lat"}.validate())
+}
+
+func TestParseMappedBool(t *testing.T) {
+	for _, value := range []string{"Y", "y", "Yes", "TRUE", "1", "x", " Y "} {
+		require.True(t, parseMappedBool(value), value)
+	}
+
+	for _, value := range []string{"N", "No", "false", "0", "", "maybe"} {
+		require.False(t, parseMappedBool(value), value)
+	}
+}
+
+func TestJsonRow(t *testing.T) {
+	var object map[string]json.RawMessage
+	require.Nil(t, json.Unmarshal([]byte(`{
+		"market_name": "Albany County Farmers' Market",
+		"latitude": 42.64819,
+		"snap": true,
+		"fmnp": null,
+		"market_link": {"url": "https://www.downtownalbany.org/albany-county-farmers-market"}
+	}`), &object))
+
+	require.Equal(t, map[string]string{
+		"market_name":     "Albany County Farmers' Market",
+		"latitude":        "42.64819",
+		"snap":            "true",
+		"market_link.url": "https://www.downtownalbany.org/albany-county-farmers-market",
+	}, jsonRow(object))
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// FileFormat is the format of a dataset file
+type FileFormat string
+
+const (
+	FileFormatJSON   FileFormat = "json"   // an array of objects, one per market
+	FileFormatNDJSON FileFormat = "ndjson" // an object per line, one per market
+	FileFormatCSV    FileFormat = "csv"    // a header row naming the columns, then a row per market
+)
+
+// fileFormatOf returns the format of a dataset file from its extension
+func fileFormatOf(path string) (FileFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FileFormatJSON, nil
+	case ".ndjson", ".jsonl":
+		return FileFormatNDJSON, nil
+	case ".csv":
+		return FileFormatCSV, nil
+	}
+
+	return "", fmt.Errorf("unknown format of %s, expected a .json, .ndjson or .csv file", path)
+}
+
+// ParseFileDataset returns the rows of a dataset in the given format, keyed by column.
+// The columns of JSON and NDJSON datasets are flattened as described in FieldMapping.
+func ParseFileDataset(data []byte, format FileFormat) ([]map[string]string, error) {
+	// Spreadsheets often save a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	switch format {
+	case FileFormatJSON:
+		var objects []map[string]json.RawMessage
+		if err := json.Unmarshal(data, &objects); err != nil {
+			return nil, fmt.Errorf("could not parse json dataset: %w", err)
+		}
+
+		rows := make([]map[string]string, 0, len(objects))
+		for _, object := range objects {
+			rows = append(rows, jsonRow(object))
+		}
+
+		return rows, nil
+
+	case FileFormatNDJSON:
+		var rows []map[string]string
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+				return nil, fmt.Errorf("could not parse line %d of ndjson dataset: %w", line, err)
+			}
+
+			rows = append(rows, jsonRow(object))
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("could not read ndjson dataset: %w", err)
+		}
+
+		return rows, nil
+
+	case FileFormatCSV:
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1 // spreadsheets leave out trailing empty cells
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("could not read header of csv dataset: %w", err)
+		}
+
+		for idx := range header {
+			header[idx] = strings.TrimSpace(header[idx])
+		}
+
+		var rows []map[string]string
+		for {
+			cells, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("could not parse csv dataset: %w", err)
+			}
+
+			// Rows left empty in a spreadsheet
+			if strings.TrimSpace(strings.Join(cells, "")) == "" {
+				continue
+			}
+
+			row := make(map[string]string, len(header))
+			for idx, cell := range cells {
+				if idx < len(header) {
+					row[header[idx]] = cell
+				}
+			}
+
+			rows = append(rows, row)
+		}
+
+		return rows, nil
+	}
+
+	return nil, fmt.Errorf("unknown dataset format: %q", format)
+}
+
+// FileDatasourceOptions describes a datasource loaded from a local file, such as the
+// spreadsheet of a market association exported as CSV
+type FileDatasourceOptions struct {
+	Name        string        `json:"name"`                  // value of the datasource query parameter
+	Title       string        `json:"title,omitempty"`       // human-readable name, Name if empty
+	Coverage    string        `json:"coverage,omitempty"`    // description of the area the file covers
+	Attribution string        `json:"attribution,omitempty"` // attribution and license text for the file
+	Area        *CoverageArea `json:"area,omitempty"`        // the area the file covers, see Datasource.Area
+
+	Path    string       `json:"path"`
+	Format  FileFormat   `json:"format,omitempty"` // inferred from the extension of Path if empty
+	Mapping FieldMapping `json:"mapping"`
+}
+
+// withDefaults returns the options with the Title and Format filled in, or an error
+// if they cannot describe a datasource
+func (options FileDatasourceOptions) withDefaults() (FileDatasourceOptions, error) {
+	if options.Name == "" || strings.Contains(options.Name, marketIDSeparator) {
+		return options, fmt.Errorf("invalid file datasource name: %q", options.Name)
+	}
+
+	if options.Path == "" {
+		return options, fmt.Errorf("file datasource %s has no path", options.Name)
+	}
+
+	if options.Title == "" {
+		options.Title = options.Name
+	}
+
+	if options.Format == "" {
+		format, err := fileFormatOf(options.Path)
+		if err != nil {
+			return options, fmt.Errorf("file datasource %s: %w", options.Name, err)
+		}
+
+		options.Format = format
+	}
+
+	if err := options.Mapping.validate(); err != nil {
+		return options, fmt.Errorf("file datasource %s: %w", options.Name, err)
+	}
+
+	return options, nil
+}
+
+// ReadFileDatasourceOptions reads a JSON array of FileDatasourceOptions. Relative
+// paths in it are relative to the directory of the file.
+func ReadFileDatasourceOptions(path string) ([]FileDatasourceOptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file datasources: %w", err)
+	}
+
+	var fileOptions []FileDatasourceOptions
+	if err := json.Unmarshal(data, &fileOptions); err != nil {
+		return nil, fmt.Errorf("could not parse file datasources: %w", err)
+	}
+
+	for idx := range fileOptions {
+		if fileOptions[idx].Path != "" && !filepath.IsAbs(fileOptions[idx].Path) {
+			fileOptions[idx].Path = filepath.Join(filepath.Dir(path), fileOptions[idx].Path)
+		}
+	}
+
+	return fileOptions, nil
+}
+
+// RegisterFileDatasource adds a datasource loaded from a local file to the registry.
+// Unlike RegisterDatasource, it is meant to be called with the configuration of the
+// service, so it returns an error instead of panicking. Only the Geocoder of the
+// Options the datasource is constructed with is used.
+func RegisterFileDatasource(fileOptions FileDatasourceOptions) error {
+	fileOptions, err := fileOptions.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := LookupDatasource(fileOptions.Name); exists {
+		return fmt.Errorf("datasource %s already exists", fileOptions.Name)
+	}
+
+	RegisterDatasource(Datasource{
+		Name:         fileOptions.Name,
+		Title:        fileOptions.Title,
+		Coverage:     fileOptions.Coverage,
+		Attribution:  fileOptions.Attribution,
+		Capabilities: fileOptions.Mapping.Capabilities(),
+		Area:         fileOptions.Area,
+		New: func(options Options) (FarmersMarketApi, error) {
+			return NewFileFarmersMarketApi(fileOptions, options.Geocoder)
+		},
+	})
+
+	return nil
+}
+
+// fileCheckInterval is how often queries check the file of a FileFarmersMarketApi for changes
+const fileCheckInterval = time.Second
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// FileFarmersMarketApi is the implementation of the FarmersMarketApi that loads the
+// markets from a local file. The file is loaded on the first query and served from
+// memory afterwards, like the New York State dataset. It is loaded again on Refresh,
+// and when a query finds that the file has changed.
+type FileFarmersMarketApi struct {
+	options      FileDatasourceOptions
+	capabilities DatasourceCapabilities
+	geocoder     Geocoder
+	dataset      *cachedDataset
+
+	loaded        atomic.Pointer[fileStamp] // of the file the current snapshot was loaded from
+	checkedAt     atomic.Int64              // when the file was last checked for changes, in Unix nanoseconds
+	checkInterval time.Duration             // replaced in tests
+	reloading     sync.Mutex                // held by the query reloading the file
+}
+
+// NewFileFarmersMarketApi returns a pointer to a freshly constructed Farmers Market Api
+// serving the file described by fileOptions. The file is not read until it is queried.
+func NewFileFarmersMarketApi(fileOptions FileDatasourceOptions, geocoder Geocoder) (*FileFarmersMarketApi, error) {
+	fileOptions, err := fileOptions.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	if geocoder == nil {
+		return nil, fmt.Errorf("geocoder not configured")
+	}
+
+	fmApi := &FileFarmersMarketApi{
+		options:       fileOptions,
+		capabilities:  fileOptions.Mapping.Capabilities(),
+		geocoder:      geocoder,
+		checkInterval: fileCheckInterval,
+	}
+
+	fmApi.dataset = newCachedDataset(fmApi.load)
+
+	return fmApi, nil
+}
+
+// load reads and converts the file
+func (fmApi *FileFarmersMarketApi) load(ctx context.Context) ([]FarmersMarketRecord, error) {
+	// Checked before reading, so that a change made while reading is noticed later
+	info, err := os.Stat(fmApi.options.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", fmApi.options.Name, err)
+	}
+
+	data, err := os.ReadFile(fmApi.options.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", fmApi.options.Name, err)
+	}
+
+	rows, err := ParseFileDataset(data, fmApi.options.Format)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", fmApi.options.Name, err)
+	}
+
+	records, err := fmApi.options.Mapping.Records(ctx, fmApi.options.Name, rows)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert %s: %w", fmApi.options.Name, err)
+	}
+
+	fmApi.loaded.Store(&fileStamp{modTime: info.ModTime(), size: info.Size()})
+
+	return records, nil
+}
+
+// Refresh reads the file again. Queries keep being served from the previous
+// version of it until the new one has been loaded.
+func (fmApi *FileFarmersMarketApi) Refresh(ctx context.Context) error {
+	return fmApi.dataset.refresh(ctx)
+}
+
+// reloadIfChanged loads the file again if it has changed since it was loaded. The file
+// is checked at most once per checkInterval and reloaded by a single query at a time,
+// the others are served from the current snapshot meanwhile. If reloading fails, the
+// current snapshot is kept and a warning is added to the QueryReport of ctx.
+func (fmApi *FileFarmersMarketApi) reloadIfChanged(ctx context.Context) {
+	loaded := fmApi.loaded.Load()
+	if loaded == nil {
+		// Not loaded yet, the query loads it
+		return
+	}
+
+	checkedAt := fmApi.checkedAt.Load()
+	now := time.Now().UnixNano()
+	if now-checkedAt < int64(fmApi.checkInterval) || !fmApi.checkedAt.CompareAndSwap(checkedAt, now) {
+		return
+	}
+
+	info, err := os.Stat(fmApi.options.Path)
+	if err == nil && info.ModTime().Equal(loaded.modTime) && info.Size() == loaded.size {
+		return
+	}
+
+	if !fmApi.reloading.TryLock() {
+		return
+	}
+	defer fmApi.reloading.Unlock()
+
+	if err == nil {
+		err = fmApi.dataset.refresh(ctx)
+	}
+
+	if err != nil {
+		reportWarning(ctx, "the %s file could not be reloaded, returned the markets it listed before", fmApi.options.Name)
+	}
+}
+
+func (fmApi *FileFarmersMarketApi) NearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error) {
+	if err := filter.supportedBy(fmApi.options.Name, fmApi.capabilities); err != nil {
+		return nil, err
+	}
+
+	fmApi.reloadIfChanged(ctx)
+
+	return fmApi.dataset.nearestN(ctx, n, location, filter)
+}
+
+func (fmApi *FileFarmersMarketApi) NearestNByZipCode(ctx context.Context, n int, zipcode string, filter Filter) ([]FarmersMarketRecord, error) {
+	location, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return fmApi.NearestN(ctx, n, location, filter)
+}
+
+func (fmApi *FileFarmersMarketApi) WithinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error) {
+	fmApi.reloadIfChanged(ctx)
+
+	return fmApi.dataset.withinRadius(ctx, location, distance)
+}
+
+func (fmApi *FileFarmersMarketApi) WithinRadiusByZipCode(ctx context.Context, zipcode string, distance float64) ([]FarmersMarketRecord, error) {
+	location, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return fmApi.WithinRadius(ctx, location, distance)
+}
+
+func (fmApi *FileFarmersMarketApi) WithinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error) {
+	fmApi.reloadIfChanged(ctx)
+
+	return fmApi.dataset.withinBounds(ctx, southWest, northEast, limit)
+}
+
+func (fmApi *FileFarmersMarketApi) Market(ctx context.Context, id string) (FarmersMarketRecord, error) {
+	fmApi.reloadIfChanged(ctx)
+
+	return fmApi.dataset.market(ctx, id)
+}
+
+// Verify that FileFarmersMarketApi implements FarmersMarketApi
+var _ FarmersMarketApi = (*FileFarmersMarketApi)(nil)
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const fileTestCSV = "\xef\xbb\xbfMarket ID,Market,Address,Town,State,Lat,Lon,Hours,Season,SNAP?\n" +
+	"1,Pearl Street Market,51 S. Pearl St,Albany,NY,42.64819,-73.75383,Sun 10am-2pm,July 14-September 29,Y\n" +
+	",,,,,,,,,\n" +
+	"2,Troy Waterfront Market,River St,Troy,NY,42.73,-73.69\n"
+
+func TestParseFileDataset(t *testing.T) {
+	rows, err := ParseFileDataset([]byte(fileTestCSV), FileFormatCSV)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(rows))
+	require.Equal(t, "Pearl Street Market", rows[0]["Market"])
+	require.Equal(t, "1", rows[0]["Market ID"])
+	require.Equal(t, "Troy", rows[1]["Town"])
+	require.Equal(t, "", rows[1]["SNAP?"])
+
+	rows, err = ParseFileDataset([]byte(`[{"name": "Pearl Street Market", "location": {"lat": 42.64819, "lon": -73.75383}}]`), FileFormatJSON)
+	require.Nil(t, err)
+	require.Equal(t, []map[string]string{{"name": "Pearl Street Market", "location.lat": "42.64819", "location.lon": "-73.75383"}}, rows)
+
+	rows, err = ParseFileDataset([]byte("{\"name\": \"Pearl Street Market\"}\n\n{\"name\": \"Troy Waterfront Market\"}\n"), FileFormatNDJSON)
+	require.Nil(t, err)
+	require.Equal(t, []map[string]string{{"name": "Pearl Street Market"}, {"name": "Troy Waterfront Market"}}, rows)
+
+	_, err = ParseFileDataset([]byte("{\"name\": \"Pearl Street Market\"}\n{\"name\": "), FileFormatNDJSON)
+	require.ErrorContains(t, err, "line 2")
+
+	_, err = ParseFileDataset([]byte(`[]`), "xml")
+	require.NotNil(t, err)
+}
+
+func TestFileDatasourceOptions(t *testing.T) {
+	options, err := FileDatasourceOptions{Name: "association", Path: "markets.CSV", Mapping: testFieldMapping}.withDefaults()
+	require.Nil(t, err)
+	require.Equal(t, FileFormatCSV, options.Format)
+	require.Equal(t, "association", options.Title)
+
+	_, err = FileDatasourceOptions{Name: "association", Path: "markets.xlsx", Mapping: testFieldMapping}.withDefaults()
+	require.NotNil(t, err)
+
+	_, err = FileDatasourceOptions{Name: "association:2024", Path: "markets.csv", Mapping: testFieldMapping}.withDefaults()
+	require.NotNil(t, err)
+
+	_, err = FileDatasourceOptions{Name: "association", Path: "markets.csv"}.withDefaults()
+	require.NotNil(t, err)
+
+	// Names are not shared with other datasources
+	require.NotNil(t, RegisterFileDatasource(FileDatasourceOptions{Name: "usda", Path: "markets.csv", Mapping: testFieldMapping}))
+
+	// Paths are relative to the file describing the datasources
+	dir := t.TempDir()
+	path := filepath.Join(dir, "datasources.json")
+	require.Nil(t, os.WriteFile(path, []byte(`[{"name": "association", "path": "markets.csv", "mapping": {"name": "Market", "latitude": "Lat", "longitude": "Lon"}}]`), 0o644))
+
+	fileOptions, err := ReadFileDatasourceOptions(path)
+	require.Nil(t, err)
+	require.Equal(t, []FileDatasourceOptions{{
+		Name:    "association",
+		Path:    filepath.Join(dir, "markets.csv"),
+		Mapping: FieldMapping{Name: "Market", Latitude: "Lat", Longitude: "Lon"},
+	}}, fileOptions)
+}
+
+// newTestFileApi returns a file datasource serving a file with the given contents,
+// checked for changes on every query, and the path of the file
+func newTestFileApi(t *testing.T, contents string, mapping FieldMapping) (*FileFarmersMarketApi, string) {
+	path := filepath.Join(t.TempDir(), "markets.csv")
+	require.Nil(t, os.WriteFile(path, []byte(contents), 0o644))
+
+	fmApi, err := NewFileFarmersMarketApi(FileDatasourceOptions{Name: "association", Path: path, Mapping: mapping}, GeocoderChain{})
+	require.Nil(t, err)
+
+	fmApi.checkInterval = 0
+
+	return fmApi, path
+}
+
+func TestFileFarmersMarketApi(t *testing.T) {
+	fmApi, _ := newTestFileApi(t, fileTestCSV, testFieldMapping)
+
+	records, err := fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "Pearl Street Market", records[0].Name)
+	require.Less(t, records[0].Distance, records[1].Distance)
+
+	records, err = fmApi.NearestN(context.Background(), -1, albany, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{FarmersMarketNutritionProgram: true})
+	require.Nil(t, err)
+
+	record, err := fmApi.Market(context.Background(), "association:2")
+	require.Nil(t, err)
+	require.Equal(t, "Troy Waterfront Market", record.Name)
+
+	_, err = fmApi.Market(context.Background(), "association:3")
+	require.ErrorIs(t, err, ErrMarketNotFound)
+}
+
+func TestFileFarmersMarketApiUnsupportedFilter(t *testing.T) {
+	// The SNAP column is not mapped
+	fmApi, _ := newTestFileApi(t, fileTestCSV, FieldMapping{Name: "Market", Latitude: "Lat", Longitude: "Lon"})
+
+	_, err := fmApi.NearestN(context.Background(), -1, albany, Filter{SnapStatus: true})
+	require.ErrorIs(t, err, ErrUnsupportedFilter)
+}
+
+func TestFileFarmersMarketApiReloadsChangedFile(t *testing.T) {
+	fmApi, path := newTestFileApi(t, fileTestCSV, testFieldMapping)
+
+	records, err := fmApi.WithinRadius(context.Background(), albany, 50000)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+
+	// A market is added
+	changed := fileTestCSV + "3,Schenectady Greenmarket,Jay St,Schenectady,NY,42.81,-73.94\n"
+	require.Nil(t, os.WriteFile(path, []byte(changed), 0o644))
+
+	records, err = fmApi.WithinRadius(context.Background(), albany, 50000)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+
+	// A file that cannot be parsed leaves the markets loaded before
+	require.Nil(t, os.WriteFile(path, []byte("Market,Lat,Lon\nBroken,north,west\n"), 0o644))
+
+	ctx, report := WithQueryReport(context.Background())
+	records, err = fmApi.WithinRadius(ctx, albany, 50000)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, []string{"the association file could not be reloaded, returned the markets it listed before"}, report.Warnings())
+
+	// Refresh reads the file whether it changed or not
+	require.NotNil(t, fmApi.Refresh(context.Background()))
+
+	require.Nil(t, os.WriteFile(path, []byte(fileTestCSV), 0o644))
+	require.Nil(t, fmApi.Refresh(context.Background()))
+
+	records, err = fmApi.WithinRadius(context.Background(), albany, 50000)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+}
+
+func TestFileFarmersMarketApiSkipsMalformedRows(t *testing.T) {
+	mixed := "Market ID,Market,Address,Town,State,Lat,Lon\n" +
+		"1,Pearl Street Market,51 S. Pearl St,Albany,NY,42.64819,-73.75383\n" +
+		"2,,River St,Troy,NY,42.73,-73.69\n" +
+		"3,Schenectady Greenmarket,Jay St,Schenectady,NY,north,-73.94\n" +
+		"4,Troy Waterfront Market,River St,Troy,NY,42.73,-73.69\n"
+	fmApi, _ := newTestFileApi(t, mixed, testFieldMapping)
+
+	ctx, report := WithQueryReport(context.Background())
+	records, err := fmApi.WithinRadius(ctx, albany, 50000)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+
+	warnings := report.Warnings()
+	require.Equal(t, 2, len(warnings))
+	require.Contains(t, warnings[0], "row 2")
+	require.Contains(t, warnings[1], "row 3")
+}
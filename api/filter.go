@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedFilter is returned when a query filters on data the datasource does not have
+var ErrUnsupportedFilter = errors.New("filter not supported by datasource")
+
+// Filter selects which farmers' markets a query returns. The zero Filter selects every market.
+type Filter struct {
+	SnapStatus                    bool   // only markets that accept SNAP
+	FarmersMarketNutritionProgram bool   // only markets that are part of the Farmers Market Nutrition Program
+	Season                        Season // only markets operating in this season, if not zero
+
+	// OpenAt selects only markets open at this instant, in the time zone of the state
+	// each market is in. Markets whose hours could not be parsed are not selected.
+	OpenAt time.Time
+
+	// InSeasonOn selects only markets whose season includes this date. Markets whose
+	// season could not be parsed are not selected.
+	InSeasonOn time.Time
+}
+
+// IsEmpty returns true if the filter selects every market
+func (filter Filter) IsEmpty() bool {
+	return filter == Filter{}
+}
+
+// supportedBy returns an error wrapping ErrUnsupportedFilter if the filter needs data
+// a datasource with the given capabilities does not have, since filtering on it would
+// silently return nothing
+func (filter Filter) supportedBy(datasource string, capabilities DatasourceCapabilities) error {
+	if (filter.SnapStatus || filter.FarmersMarketNutritionProgram) && !capabilities.HasSnapData {
+		return fmt.Errorf("%w: %s has no SNAP or FMNP data", ErrUnsupportedFilter, datasource)
+	}
+
+	if filter.Season != 0 && !capabilities.HasOperationMonths {
+		return fmt.Errorf("%w: %s has no operation months", ErrUnsupportedFilter, datasource)
+	}
+
+	if !filter.OpenAt.IsZero() && !capabilities.HasOperationHours {
+		return fmt.Errorf("%w: %s has no operation hours", ErrUnsupportedFilter, datasource)
+	}
+
+	if !filter.InSeasonOn.IsZero() && !capabilities.HasOperationSeason {
+		return fmt.Errorf("%w: %s has no operation seasons", ErrUnsupportedFilter, datasource)
+	}
+
+	return nil
+}
+
+// Matches returns true if the filter selects record. A year-round market operates in every season.
+func (filter Filter) Matches(record FarmersMarketRecord) bool {
+	if filter.SnapStatus && !record.SnapStatus {
+		return false
+	}
+
+	if filter.FarmersMarketNutritionProgram && !record.FarmersMarketNutritionProgram {
+		return false
+	}
+
+	if filter.Season != 0 && !record.Seasons.OperatesIn(filter.Season) {
+		return false
+	}
+
+	if !filter.OpenAt.IsZero() {
+		location, err := StateTimeZone(record.Address.State)
+		if err != nil || !record.OperationSchedule.IsOpen(filter.OpenAt.In(location)) {
+			return false
+		}
+	}
+
+	if !filter.InSeasonOn.IsZero() {
+		if record.SeasonStart == nil || record.SeasonEnd == nil || !InSeason(*record.SeasonStart, *record.SeasonEnd, filter.InSeasonOn) {
+			return false
+		}
+	}
+
+	return true
+}
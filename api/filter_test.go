@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatches(t *testing.T) {
+	summer := FarmersMarketRecord{Seasons: NewSeasonSet(Summer), SnapStatus: true}
+	yearRound := FarmersMarketRecord{Seasons: NewSeasonSet(YearRound), FarmersMarketNutritionProgram: true}
+
+	require.True(t, Filter{}.Matches(summer))
+	require.True(t, Filter{SnapStatus: true}.Matches(summer))
+	require.False(t, Filter{SnapStatus: true}.Matches(yearRound))
+	require.True(t, Filter{FarmersMarketNutritionProgram: true}.Matches(yearRound))
+
+	require.True(t, Filter{Season: Summer}.Matches(summer))
+	require.False(t, Filter{Season: Winter}.Matches(summer))
+	require.True(t, Filter{Season: Winter}.Matches(yearRound))
+}
+
+func TestFilterOpenAt(t *testing.T) {
+	schedule, err := ParseOperationHours("Sun 10am-2pm")
+	require.Nil(t, err)
+
+	albany := FarmersMarketRecord{Address: FarmersMarketAddress{State: "NY"}, OperationSchedule: schedule}
+	unknownState := FarmersMarketRecord{OperationSchedule: schedule}
+
+	// 11am in New York on Sunday 2024-05-05
+	sundayMorning := time.Date(2024, 5, 5, 15, 0, 0, 0, time.UTC)
+
+	require.True(t, Filter{OpenAt: sundayMorning}.Matches(albany))
+	require.False(t, Filter{OpenAt: sundayMorning.Add(4 * time.Hour)}.Matches(albany))
+	require.False(t, Filter{OpenAt: sundayMorning}.Matches(unknownState))
+	require.False(t, Filter{OpenAt: sundayMorning}.Matches(FarmersMarketRecord{Address: albany.Address}))
+}
+
+func TestCachedDatasetFiltersBeforeTruncating(t *testing.T) {
+	records := []FarmersMarketRecord{
+		{Name: "Near", Location: geography.HaversinePoint{Latitude: 40.78, Longitude: -73.95}, Distance: -1},
+		{Name: "Far", Location: geography.HaversinePoint{Latitude: 42.65, Longitude: -73.75}, SnapStatus: true, Distance: -1},
+	}
+
+	dataset := newCachedDataset(func(ctx context.Context) ([]FarmersMarketRecord, error) {
+		return records, nil
+	})
+
+	found, err := dataset.nearestN(context.Background(), 1, upperEastSide, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+	require.Equal(t, "Far", found[0].Name)
+
+	// n = -1 returns every matching market
+	found, err = dataset.nearestN(context.Background(), -1, upperEastSide, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+}
+
+func TestUnsupportedFilter(t *testing.T) {
+	fmApi, err := NewUSDAFarmersMarketApi(Options{USDA: NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy)), Geocoder: GeocoderChain{}})
+	require.Nil(t, err)
+
+	// Rejected before anything is fetched
+	_, err = fmApi.NearestN(context.Background(), 10, upperEastSide, Filter{SnapStatus: true})
+	require.ErrorIs(t, err, ErrUnsupportedFilter)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+//go:generate go run ./internal/gengazetteer -o data/zcta_centroids.tsv.gz
+
+// embeddedGazetteer is the gzipped ZCTA centroid table the binary ships with.
+// It is generated from the U.S. Census Bureau Gazetteer Files by
+// internal/gengazetteer, see ParseGazetteer for the format.
+//
+//go:embed data/zcta_centroids.tsv.gz
+var embeddedGazetteer []byte
+
+// Gazetteer is an offline Geocoder backed by a table of ZIP Code Tabulation Area
+// (ZCTA) centroids. ZCTAs are the Census Bureau's approximation of the areas
+// served by each zip code, so PO box only zip codes are not in the table.
+type Gazetteer struct {
+	// zipCodes is sorted in ascending order and points[i] is the centroid of zipCodes[i].
+	// Keeping the zip codes as integers in a sorted slice is a lot more compact than a map.
+	zipCodes []int32
+	points   []geography.HaversinePoint
+}
+
+// ParseGazetteer reads an uncompressed gazetteer table. The table is tab separated
+// with a header line followed by one "zip\tlatitude\tlongitude" line per zip code.
+func ParseGazetteer(reader io.Reader) (*Gazetteer, error) {
+	type entry struct {
+		zipCode int32
+		point   geography.HaversinePoint
+	}
+
+	var entries []entry
+
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		// Skip the header
+		if lineNumber == 1 {
+			continue
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("gazetteer line %d: expected 3 fields, got %d", lineNumber, len(fields))
+		}
+
+		zipCode, err := parseZipCode(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: %w", lineNumber, err)
+		}
+
+		latitude, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: latitude malformatted: %w", lineNumber, err)
+		}
+
+		longitude, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: longitude malformatted: %w", lineNumber, err)
+		}
+
+		entries = append(entries, entry{zipCode, geography.HaversinePoint{Latitude: latitude, Longitude: longitude}})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading gazetteer: %w", err)
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Compare(a.zipCode, b.zipCode)
+	})
+
+	gazetteer := &Gazetteer{
+		zipCodes: make([]int32, len(entries)),
+		points:   make([]geography.HaversinePoint, len(entries)),
+	}
+
+	for idx, entry := range entries {
+		gazetteer.zipCodes[idx] = entry.zipCode
+		gazetteer.points[idx] = entry.point
+	}
+
+	return gazetteer, nil
+}
+
+// parseZipCode converts a 5 digit zip code into an integer
+func parseZipCode(zipCode string) (int32, error) {
+	if len(zipCode) != 5 {
+		return 0, fmt.Errorf("zip code %q is not 5 digits", zipCode)
+	}
+
+	value, err := strconv.ParseInt(zipCode, 10, 32)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("zip code %q is not 5 digits", zipCode)
+	}
+
+	return int32(value), nil
+}
+
+var defaultGazetteer = sync.OnceValues(func() (*Gazetteer, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(embeddedGazetteer))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing embedded gazetteer: %w", err)
+	}
+
+	defer reader.Close()
+
+	gazetteer, err := ParseGazetteer(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// A binary built without generating the table would not resolve any zip code
+	if gazetteer.Len() == 0 {
+		return nil, fmt.Errorf("the embedded gazetteer is empty, run go generate ./api")
+	}
+
+	return gazetteer, nil
+})
+
+// DefaultGazetteer returns the Gazetteer for the table embedded in the binary.
+// The table is only parsed the first time this is called. It fails if the table
+// has not been generated.
+func DefaultGazetteer() (*Gazetteer, error) {
+	return defaultGazetteer()
+}
+
+// Len returns the number of zip codes in the gazetteer
+func (gazetteer *Gazetteer) Len() int {
+	return len(gazetteer.zipCodes)
+}
+
+// ZipCodeToHaversinePoint implements the Geocoder interface
+func (gazetteer *Gazetteer) ZipCodeToHaversinePoint(_ context.Context, zipCode string) (geography.HaversinePoint, error) {
+	value, err := parseZipCode(zipCode)
+	if err != nil {
+		return geography.HaversinePoint{}, fmt.Errorf("gazetteer: %w: %w", ErrZipCodeNotFound, err)
+	}
+
+	idx, found := slices.BinarySearch(gazetteer.zipCodes, value)
+	if !found {
+		return geography.HaversinePoint{}, fmt.Errorf("gazetteer: %w: %s", ErrZipCodeNotFound, zipCode)
+	}
+
+	return gazetteer.points[idx], nil
+}
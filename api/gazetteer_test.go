@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+const testGazetteerTable = `zip	latitude	longitude
+77001	29.813142	-95.309789
+00601	18.180555	-66.749961
+10023	40.776272	-73.982508
+`
+
+func TestParseGazetteer(t *testing.T) {
+	gazetteer, err := ParseGazetteer(strings.NewReader(testGazetteerTable))
+	require.Nil(t, err)
+	require.Equal(t, 3, gazetteer.Len())
+
+	point, err := gazetteer.ZipCodeToHaversinePoint(context.Background(), "00601")
+	require.Nil(t, err)
+	require.Equal(t, geography.HaversinePoint{Latitude: 18.180555, Longitude: -66.749961}, point)
+
+	_, err = gazetteer.ZipCodeToHaversinePoint(context.Background(), "99999")
+	require.ErrorIs(t, err, ErrZipCodeNotFound)
+
+	_, err = gazetteer.ZipCodeToHaversinePoint(context.Background(), "1002")
+	require.ErrorIs(t, err, ErrZipCodeNotFound)
+}
+
+func TestParseGazetteerMalformatted(t *testing.T) {
+	_, err := ParseGazetteer(strings.NewReader("zip\tlatitude\tlongitude\n77001\tnorth\t-95.3\n"))
+	require.NotNil(t, err)
+}
+
+func TestEmbeddedGazetteerLoads(t *testing.T) {
+	_, err := DefaultGazetteer()
+	require.Nil(t, err)
+}
+
+func TestEmbeddedGazetteerResolvesZipCodesOffline(t *testing.T) {
+	gazetteer, err := DefaultGazetteer()
+	require.Nil(t, err)
+
+	// Without geonames credentials, the chain is the gazetteer alone
+	chain, err := NewGeocoderChain([]string{GazetteerGeocoderName, GeoNamesGeocoderName}, Options{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(chain))
+
+	for zipCode, expected := range map[string]geography.HaversinePoint{
+		"10023": {Latitude: 40.776, Longitude: -73.983}, // Upper West Side
+		"77001": {Latitude: 29.813, Longitude: -95.310}, // Houston
+		"00601": {Latitude: 18.181, Longitude: -66.750}, // Adjuntas
+	} {
+		point, err := chain.ZipCodeToHaversinePoint(context.Background(), zipCode)
+		require.Nil(t, err, zipCode)
+
+		distance, err := geography.DefaultHaversineMetricSpace.Distance(point, expected)
+		require.Nil(t, err)
+		require.Less(t, distance, 3000.0, zipCode)
+	}
+
+	require.Greater(t, gazetteer.Len(), 30000)
+}
+
+// geocoderFunc lets a function be used as a Geocoder
+type geocoderFunc func(ctx context.Context, zipCode string) (geography.HaversinePoint, error)
+
+func (f geocoderFunc) ZipCodeToHaversinePoint(ctx context.Context, zipCode string) (geography.HaversinePoint, error) {
+	return f(ctx, zipCode)
+}
+
+func TestGeocoderChainFallsThrough(t *testing.T) {
+	gazetteer, err := ParseGazetteer(strings.NewReader(testGazetteerTable))
+	require.Nil(t, err)
+
+	fallbackCalls := 0
+	fallback := geocoderFunc(func(_ context.Context, zipCode string) (geography.HaversinePoint, error) {
+		fallbackCalls++
+		if zipCode == "12207" {
+			return geography.HaversinePoint{Latitude: 42.65, Longitude: -73.75}, nil
+		}
+
+		return geography.HaversinePoint{}, errors.New("upstream failed")
+	})
+
+	chain := GeocoderChain{gazetteer, fallback}
+
+	point, err := chain.ZipCodeToHaversinePoint(context.Background(), "77001")
+	require.Nil(t, err)
+	require.Equal(t, 29.813142, point.Latitude)
+	require.Equal(t, 0, fallbackCalls)
+
+	point, err = chain.ZipCodeToHaversinePoint(context.Background(), "12207")
+	require.Nil(t, err)
+	require.Equal(t, 42.65, point.Latitude)
+	require.Equal(t, 1, fallbackCalls)
+
+	// The fallback failing is not the same as the zip code not existing
+	_, err = chain.ZipCodeToHaversinePoint(context.Background(), "99999")
+	require.NotNil(t, err)
+	require.NotErrorIs(t, err, ErrZipCodeNotFound)
+
+	_, err = GeocoderChain{gazetteer}.ZipCodeToHaversinePoint(context.Background(), "99999")
+	require.ErrorIs(t, err, ErrZipCodeNotFound)
+}
+
+func TestNewGeocoderChain(t *testing.T) {
+	chain, err := NewGeocoderChain([]string{GeoNamesGeocoderName, GazetteerGeocoderName}, Options{})
+	require.Nil(t, err)
+	// geonames is skipped without a client
+	require.Equal(t, 1, len(chain))
+
+	chain, err = NewGeocoderChain([]string{GeoNamesGeocoderName, GazetteerGeocoderName}, Options{GeoNames: NewGeoNamesClient("user", nil)})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(chain))
+	require.IsType(t, &GeoNamesClient{}, chain[0])
+
+	_, err = NewGeocoderChain([]string{"carrier-pigeon"}, Options{})
+	require.NotNil(t, err)
+}
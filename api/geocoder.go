@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// ErrZipCodeNotFound is returned by a Geocoder that does not know the zip code it was asked about
+var ErrZipCodeNotFound = errors.New("zip code not found")
+
+// Geocoder converts a US zip code into a location
+type Geocoder interface {
+	// ZipCodeToHaversinePoint returns the location of the given 5 digit zip code.
+	// It returns an error wrapping ErrZipCodeNotFound if the zip code is unknown.
+	ZipCodeToHaversinePoint(ctx context.Context, zipCode string) (geography.HaversinePoint, error)
+}
+
+// GeocoderChain is a Geocoder that asks each of its Geocoders in order, falling
+// through to the next one whenever a Geocoder fails.
+type GeocoderChain []Geocoder
+
+// ZipCodeToHaversinePoint implements the Geocoder interface. The error it returns
+// only wraps ErrZipCodeNotFound if every Geocoder in the chain reported the zip
+// code as unknown, otherwise it wraps the errors of the Geocoders that failed.
+func (chain GeocoderChain) ZipCodeToHaversinePoint(ctx context.Context, zipCode string) (geography.HaversinePoint, error) {
+	var errs []error
+	var notFoundErrs []error
+
+	for _, geocoder := range chain {
+		point, err := geocoder.ZipCodeToHaversinePoint(ctx, zipCode)
+		if err == nil {
+			return point, nil
+		}
+
+		if errors.Is(err, ErrZipCodeNotFound) {
+			notFoundErrs = append(notFoundErrs, err)
+		} else {
+			errs = append(errs, err)
+		}
+
+		// There is no point asking the rest of the chain if we have run out of time
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return geography.HaversinePoint{}, errors.Join(errs...)
+	}
+
+	if len(notFoundErrs) == 0 {
+		return geography.HaversinePoint{}, fmt.Errorf("no geocoders configured: %w", ErrZipCodeNotFound)
+	}
+
+	return geography.HaversinePoint{}, errors.Join(notFoundErrs...)
+}
+
+// Names of the geocoders accepted by NewGeocoderChain
+const (
+	GazetteerGeocoderName = "gazetteer" // the embedded ZCTA centroid table, see DefaultGazetteer
+	GeoNamesGeocoderName  = "geonames"  // the geonames.org postal code lookup, see GeoNamesClient
+)
+
+// DefaultGeocoderOrder is the order geocoders are asked in unless configured otherwise.
+// The embedded gazetteer answers most lookups without leaving the process, geonames
+// is only asked about the zip codes it does not know.
+var DefaultGeocoderOrder = []string{GazetteerGeocoderName, GeoNamesGeocoderName}
+
+// NewGeocoderChain returns a GeocoderChain asking the named geocoders in the given order.
+// The geonames geocoder is skipped if options.GeoNames is nil, since its credentials
+// are optional.
+func NewGeocoderChain(order []string, options Options) (GeocoderChain, error) {
+	chain := GeocoderChain{}
+
+	for _, name := range order {
+		switch name {
+		case GazetteerGeocoderName:
+			gazetteer, err := DefaultGazetteer()
+			if err != nil {
+				return nil, fmt.Errorf("loading gazetteer: %w", err)
+			}
+
+			chain = append(chain, gazetteer)
+		case GeoNamesGeocoderName:
+			if options.GeoNames != nil {
+				chain = append(chain, options.GeoNames)
+			}
+		default:
+			return nil, fmt.Errorf("unknown geocoder: %s", name)
+		}
+	}
+
+	return chain, nil
+}
+
+// Verify that the geocoders implement Geocoder
+var _ Geocoder = (GeocoderChain)(nil)
+var _ Geocoder = (*GeoNamesClient)(nil)
+var _ Geocoder = (*Gazetteer)(nil)
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// GeocoderCacheOptions configures a CachingGeocoder
+type GeocoderCacheOptions struct {
+	Capacity    int           // maximum number of zip codes kept in memory
+	TTL         time.Duration // how long a location is cached for
+	NegativeTTL time.Duration // how long a zip code that was not found is cached for
+	Path        string        // file the cache is persisted to across restarts, not persisted if empty
+}
+
+// DefaultGeocoderCacheOptions returns the options the service uses unless told otherwise.
+// Zip code centroids almost never change, so locations are kept for a long time.
+// Unknown zip codes are forgotten sooner in case a geocoder learns about them.
+func DefaultGeocoderCacheOptions() GeocoderCacheOptions {
+	return GeocoderCacheOptions{
+		Capacity:    50000,
+		TTL:         90 * 24 * time.Hour,
+		NegativeTTL: 24 * time.Hour,
+	}
+}
+
+// GeocoderCacheStats are the counters of a CachingGeocoder
+type GeocoderCacheStats struct {
+	Hits          uint64 // lookups answered with a cached location
+	NegativeHits  uint64 // lookups answered with a cached "zip code not found"
+	Misses        uint64 // lookups passed on to the underlying Geocoder
+	PersistErrors uint64 // entries that could not be appended to the cache file
+	Entries       int    // zip codes currently cached in memory
+}
+
+// geocoderCacheEntry is a cached answer, and also the line format of the cache file
+type geocoderCacheEntry struct {
+	ZipCode   string                   `json:"zip"`
+	Location  geography.HaversinePoint `json:"location"`
+	Found     bool                     `json:"found"`
+	ExpiresAt time.Time                `json:"expires_at"`
+}
+
+// CachingGeocoder is a Geocoder that caches the answers of another Geocoder in a
+// least recently used cache, optionally persisted to disk. Zip codes the
+// underlying Geocoder reports as not found are cached too, failures are not.
+type CachingGeocoder struct {
+	geocoder Geocoder
+	options  GeocoderCacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // values are *geocoderCacheEntry
+	order   *list.List               // front is the most recently used
+	file    *os.File                 // append-only log of new entries, nil if not persisted
+
+	hits          atomic.Uint64
+	negativeHits  atomic.Uint64
+	misses        atomic.Uint64
+	persistErrors atomic.Uint64
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewCachingGeocoder returns a CachingGeocoder in front of geocoder. If options.Path
+// is set, unexpired entries are loaded from it and new entries are appended to it.
+func NewCachingGeocoder(geocoder Geocoder, options GeocoderCacheOptions) (*CachingGeocoder, error) {
+	if options.Capacity <= 0 {
+		return nil, fmt.Errorf("geocoder cache capacity must be positive")
+	}
+
+	cache := &CachingGeocoder{
+		geocoder: geocoder,
+		options:  options,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+
+	if options.Path != "" {
+		if err := cache.loadFile(); err != nil {
+			return nil, fmt.Errorf("loading geocoder cache: %w", err)
+		}
+	}
+
+	return cache, nil
+}
+
+// loadFile reads the unexpired entries in the cache file, then rewrites the file
+// with only those entries so it does not grow forever, and opens it for appending.
+func (cache *CachingGeocoder) loadFile() error {
+	now := cache.now()
+
+	file, err := os.Open(cache.options.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry geocoderCacheEntry
+
+			// A partially written line from a crash is not worth failing over
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue
+			}
+
+			if entry.ExpiresAt.After(now) {
+				cache.put(entry)
+			}
+		}
+
+		file.Close()
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cache.options.Path), 0755); err != nil {
+		return err
+	}
+
+	// Rewrite the compacted file next to the old one and swap it in atomically
+	temporaryPath := cache.options.Path + ".tmp"
+	temporaryFile, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(temporaryFile)
+	encoder := json.NewEncoder(writer)
+
+	// Oldest first, so that replaying the file restores the same recency order
+	for element := cache.order.Back(); element != nil; element = element.Prev() {
+		if err := encoder.Encode(element.Value.(*geocoderCacheEntry)); err != nil {
+			temporaryFile.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		temporaryFile.Close()
+		return err
+	}
+
+	if err := temporaryFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temporaryPath, cache.options.Path); err != nil {
+		return err
+	}
+
+	cache.file, err = os.OpenFile(cache.options.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+// put adds an entry to the front of the in-memory cache, evicting the least
+// recently used entry if the cache is full. The caller must hold cache.mu
+// unless the cache is still being constructed.
+func (cache *CachingGeocoder) put(entry geocoderCacheEntry) {
+	if element, ok := cache.entries[entry.ZipCode]; ok {
+		element.Value = &entry
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[entry.ZipCode] = cache.order.PushFront(&entry)
+
+	for cache.order.Len() > cache.options.Capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*geocoderCacheEntry).ZipCode)
+	}
+}
+
+// get returns the unexpired entry for a zip code
+func (cache *CachingGeocoder) get(zipCode string) (geocoderCacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[zipCode]
+	if !ok {
+		return geocoderCacheEntry{}, false
+	}
+
+	entry := element.Value.(*geocoderCacheEntry)
+	if !entry.ExpiresAt.After(cache.now()) {
+		cache.order.Remove(element)
+		delete(cache.entries, zipCode)
+		return geocoderCacheEntry{}, false
+	}
+
+	cache.order.MoveToFront(element)
+	return *entry, true
+}
+
+// store caches an entry in memory and appends it to the cache file
+func (cache *CachingGeocoder) store(entry geocoderCacheEntry) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.put(entry)
+
+	if cache.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = cache.file.Write(append(line, '\n'))
+	return err
+}
+
+// ZipCodeToHaversinePoint implements the Geocoder interface
+func (cache *CachingGeocoder) ZipCodeToHaversinePoint(ctx context.Context, zipCode string) (geography.HaversinePoint, error) {
+	if entry, ok := cache.get(zipCode); ok {
+		if !entry.Found {
+			cache.negativeHits.Add(1)
+			return geography.HaversinePoint{}, fmt.Errorf("cached: %w: %s", ErrZipCodeNotFound, zipCode)
+		}
+
+		cache.hits.Add(1)
+		return entry.Location, nil
+	}
+
+	cache.misses.Add(1)
+
+	point, err := cache.geocoder.ZipCodeToHaversinePoint(ctx, zipCode)
+
+	entry := geocoderCacheEntry{ZipCode: zipCode, Location: point}
+
+	switch {
+	case err == nil:
+		entry.Found = true
+		entry.ExpiresAt = cache.now().Add(cache.options.TTL)
+	case errors.Is(err, ErrZipCodeNotFound):
+		entry.ExpiresAt = cache.now().Add(cache.options.NegativeTTL)
+	default:
+		// Do not cache failures, the next lookup might succeed
+		return point, err
+	}
+
+	if storeErr := cache.store(entry); storeErr != nil {
+		// The answer is still good even if we could not persist it
+		cache.persistErrors.Add(1)
+	}
+
+	return point, err
+}
+
+// Stats returns the counters of the cache
+func (cache *CachingGeocoder) Stats() GeocoderCacheStats {
+	cache.mu.Lock()
+	entries := cache.order.Len()
+	cache.mu.Unlock()
+
+	return GeocoderCacheStats{
+		Hits:          cache.hits.Load(),
+		NegativeHits:  cache.negativeHits.Load(),
+		Misses:        cache.misses.Load(),
+		PersistErrors: cache.persistErrors.Load(),
+		Entries:       entries,
+	}
+}
+
+// Close closes the cache file, if any
+func (cache *CachingGeocoder) Close() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.file == nil {
+		return nil
+	}
+
+	err := cache.file.Close()
+	cache.file = nil
+
+	return err
+}
+
+// Verify that CachingGeocoder implements Geocoder
+var _ Geocoder = (*CachingGeocoder)(nil)
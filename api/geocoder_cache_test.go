@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+// countingGeocoder knows a single zip code, fails for "00000" and counts how often it was asked
+type countingGeocoder struct {
+	calls int
+}
+
+func (geocoder *countingGeocoder) ZipCodeToHaversinePoint(_ context.Context, zipCode string) (geography.HaversinePoint, error) {
+	geocoder.calls++
+
+	switch zipCode {
+	case "77001":
+		return geography.HaversinePoint{Latitude: 29.81, Longitude: -95.31}, nil
+	case "00000":
+		return geography.HaversinePoint{}, errors.New("upstream failed")
+	default:
+		return geography.HaversinePoint{}, ErrZipCodeNotFound
+	}
+}
+
+func TestCachingGeocoderHitsAndMisses(t *testing.T) {
+	underlying := &countingGeocoder{}
+	cache, err := NewCachingGeocoder(underlying, DefaultGeocoderCacheOptions())
+	require.Nil(t, err)
+
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		point, err := cache.ZipCodeToHaversinePoint(ctx, "77001")
+		require.Nil(t, err)
+		require.Equal(t, 29.81, point.Latitude)
+	}
+
+	// Unknown zip codes are cached too
+	for i := 0; i < 2; i++ {
+		_, err := cache.ZipCodeToHaversinePoint(ctx, "99999")
+		require.ErrorIs(t, err, ErrZipCodeNotFound)
+	}
+
+	// Failures are not
+	for i := 0; i < 2; i++ {
+		_, err := cache.ZipCodeToHaversinePoint(ctx, "00000")
+		require.NotNil(t, err)
+	}
+
+	require.Equal(t, 4, underlying.calls)
+	require.Equal(t, GeocoderCacheStats{Hits: 2, NegativeHits: 1, Misses: 4, Entries: 2}, cache.Stats())
+}
+
+func TestCachingGeocoderExpiryAndEviction(t *testing.T) {
+	underlying := &countingGeocoder{}
+	options := DefaultGeocoderCacheOptions()
+	options.Capacity = 1
+
+	cache, err := NewCachingGeocoder(underlying, options)
+	require.Nil(t, err)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	cache.ZipCodeToHaversinePoint(ctx, "77001")
+	cache.ZipCodeToHaversinePoint(ctx, "99999") // evicts 77001
+	cache.ZipCodeToHaversinePoint(ctx, "77001")
+	require.Equal(t, 3, underlying.calls)
+
+	// The negative entry for 99999 was evicted, and 77001 expires
+	now = now.Add(options.TTL)
+	cache.ZipCodeToHaversinePoint(ctx, "77001")
+	require.Equal(t, 4, underlying.calls)
+	require.Equal(t, 1, cache.Stats().Entries)
+}
+
+func TestCachingGeocoderPersists(t *testing.T) {
+	options := DefaultGeocoderCacheOptions()
+	options.Path = filepath.Join(t.TempDir(), "cache", "zipcodes.ndjson")
+
+	underlying := &countingGeocoder{}
+	cache, err := NewCachingGeocoder(underlying, options)
+	require.Nil(t, err)
+
+	ctx := context.Background()
+	cache.ZipCodeToHaversinePoint(ctx, "77001")
+	cache.ZipCodeToHaversinePoint(ctx, "99999")
+	require.Nil(t, cache.Close())
+
+	// A restarted cache answers from disk without asking the underlying geocoder
+	restartedUnderlying := &countingGeocoder{}
+	restarted, err := NewCachingGeocoder(restartedUnderlying, options)
+	require.Nil(t, err)
+	defer restarted.Close()
+
+	point, err := restarted.ZipCodeToHaversinePoint(ctx, "77001")
+	require.Nil(t, err)
+	require.Equal(t, -95.31, point.Longitude)
+
+	_, err = restarted.ZipCodeToHaversinePoint(ctx, "99999")
+	require.ErrorIs(t, err, ErrZipCodeNotFound)
+
+	require.Equal(t, 0, restartedUnderlying.calls)
+}
@@ -1,14 +1,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 
 	"github.com/jadidbourbaki/gofarm/geography"
 )
 
+// GeoNamesPostalCodeLookupEndpoint is the public endpoint for the geonames.org postal code lookup
+const GeoNamesPostalCodeLookupEndpoint = "http://api.geonames.org/postalCodeLookupJSON"
+
 // GeoNamesResponse is the response received from geonames.org
 type GeoNamesResponse struct {
 	PostalCodes []GeoNamesRecord `json:"postalcodes"`
@@ -27,37 +30,41 @@ type GeoNamesRecord struct {
 	Latitude    float64 `json:"lat"`
 }
 
-// Uses the geonames.org API to convert a US Zip code into a Haversine Point
-// Returns a default HaversinePoint as well as an error if it fails
-func ZipCodeToHaversinePoint(zipcode string) (geography.HaversinePoint, error) {
-	returnPoint := geography.HaversinePoint{}
+// GeoNamesClient is a client for the geonames.org postal code lookup
+type GeoNamesClient struct {
+	UpstreamClient
+	Username string
+}
 
-	req, err := http.NewRequest(http.MethodGet, "http://api.geonames.org/postalCodeLookupJSON", nil)
-	if err != nil {
-		return returnPoint, fmt.Errorf("failed to create geonames request: %w", err)
+// NewGeoNamesClient returns a client for the public geonames.org postal code lookup endpoint.
+// The guard should be shared by every client of the endpoint, see GeoNamesUpstreamPolicy,
+// a single attempt is made at every request if it is nil.
+func NewGeoNamesClient(username string, guard *UpstreamGuard) *GeoNamesClient {
+	return &GeoNamesClient{
+		UpstreamClient: UpstreamClient{
+			HTTPClient: defaultHTTPClient,
+			BaseURL:    GeoNamesPostalCodeLookupEndpoint,
+			UserAgent:  defaultUserAgent,
+			Guard:      guard,
+		},
+		Username: username,
 	}
+}
+
+// ZipCodeToHaversinePoint uses the geonames.org API to convert a US Zip code into a Haversine Point
+// Returns a default HaversinePoint as well as an error if it fails
+func (client *GeoNamesClient) ZipCodeToHaversinePoint(ctx context.Context, zipcode string) (geography.HaversinePoint, error) {
+	returnPoint := geography.HaversinePoint{}
 
-	query := req.URL.Query()
+	query := url.Values{}
 
-	query.Add("username", DefaultCredentials.geonames)
+	query.Add("username", client.Username)
 	query.Add("country", "US")
 	query.Add("postalcode", zipcode)
 
-	req.URL.RawQuery = query.Encode()
-
-	res, err := http.DefaultClient.Do(req)
+	bodyBytes, err := client.get(ctx, query, "")
 	if err != nil {
-		return returnPoint, fmt.Errorf("request failed: %w", err)
-	}
-
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		return returnPoint, fmt.Errorf("incorrect status code: %v", res.StatusCode)
-	}
-
-	bodyBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return returnPoint, fmt.Errorf("failed to read body: %w", err)
+		return returnPoint, fmt.Errorf("geonames: %w", err)
 	}
 
 	var data GeoNamesResponse
@@ -69,7 +76,7 @@ func ZipCodeToHaversinePoint(zipcode string) (geography.HaversinePoint, error) {
 	// Potentially give out an error for when there is
 	// more than 1 record returned?
 	if len(data.PostalCodes) == 0 {
-		return returnPoint, fmt.Errorf("failed to find zipcode: %s", zipcode)
+		return returnPoint, fmt.Errorf("geonames: %w: %s", ErrZipCodeNotFound, zipcode)
 	}
 
 	firstRecord := data.PostalCodes[0]
@@ -79,3 +86,11 @@ func ZipCodeToHaversinePoint(zipcode string) (geography.HaversinePoint, error) {
 
 	return returnPoint, nil
 }
+
+// Uses the public geonames.org API with DefaultCredentials to convert a US Zip code into
+// a Haversine Point. See GeoNamesClient.ZipCodeToHaversinePoint.
+func ZipCodeToHaversinePoint(ctx context.Context, zipcode string) (geography.HaversinePoint, error) {
+	client := NewGeoNamesClient(DefaultCredentials.geonames, sharedGeoNamesGuard)
+
+	return client.ZipCodeToHaversinePoint(ctx, zipcode)
+}
@@ -0,0 +1,155 @@
+// gengazetteer converts the U.S. Census Bureau ZCTA Gazetteer File [1] into the
+// compact table embedded by the api package, see api.ParseGazetteer for the format.
+//
+// Run it through go generate in the api directory:
+//
+//	go generate ./api
+//
+// By default it downloads the national ZCTA file, pass -in to convert a file that
+// has already been downloaded (either the .zip archive or the extracted .txt).
+//
+// [1]: https://www.census.gov/geographies/reference-files/time-series/geo/gazetteer-files.html
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultGazetteerURL is the national ZCTA Gazetteer File for the 2020 census
+const defaultGazetteerURL = "https://www2.census.gov/geo/docs/maps-data/data/gazetteer/2020_Gazetteer/2020_Gaz_zcta_national.zip"
+
+func main() {
+	in := flag.String("in", "", "path to the ZCTA Gazetteer File (.zip or .txt), downloaded from -url if empty")
+	url := flag.String("url", defaultGazetteerURL, "URL to download the ZCTA Gazetteer File from")
+	out := flag.String("out", "data/zcta_centroids.tsv.gz", "path to write the gzipped table to")
+	flag.StringVar(out, "o", *out, "shorthand for -out")
+	flag.Parse()
+
+	raw, err := readInput(*in, *url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	text, err := extractText(raw)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	count, err := writeTable(text, *out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("wrote %d zip codes to %s", count, *out)
+}
+
+// readInput reads the file at path, or downloads url if path is empty
+func readInput(path string, url string) ([]byte, error) {
+	if path != "" {
+		return os.ReadFile(path)
+	}
+
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading gazetteer: %w", err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading gazetteer: incorrect status code %v", res.StatusCode)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// extractText returns the gazetteer text file, unpacking it if raw is a zip archive
+func extractText(raw []byte) ([]byte, error) {
+	// Zip archives start with "PK"
+	if !bytes.HasPrefix(raw, []byte("PK")) {
+		return raw, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".txt") {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", file.Name, err)
+		}
+
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+
+	return nil, fmt.Errorf("no .txt file in archive")
+}
+
+// writeTable writes the zip code, latitude and longitude columns of the
+// gazetteer text to a gzipped table at path and returns the number of rows.
+func writeTable(text []byte, path string) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+
+	if !scanner.Scan() {
+		return 0, fmt.Errorf("gazetteer is empty")
+	}
+
+	// The header looks like
+	// GEOID ALAND AWATER ALAND_SQMI AWATER_SQMI INTPTLAT INTPTLONG
+	// with tabs in between, and sometimes trailing whitespace.
+	columns := map[string]int{}
+	for idx, name := range strings.Split(scanner.Text(), "\t") {
+		columns[strings.TrimSpace(name)] = idx
+	}
+
+	for _, name := range []string{"GEOID", "INTPTLAT", "INTPTLONG"} {
+		if _, ok := columns[name]; !ok {
+			return 0, fmt.Errorf("gazetteer is missing column %s", name)
+		}
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	fmt.Fprintln(writer, "zip\tlatitude\tlongitude")
+
+	count := 0
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < len(columns) {
+			continue
+		}
+
+		zipCode := strings.TrimSpace(fields[columns["GEOID"]])
+		latitude := strings.TrimSpace(fields[columns["INTPTLAT"]])
+		longitude := strings.TrimSpace(fields[columns["INTPTLONG"]])
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", zipCode, latitude, longitude)
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading gazetteer: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	return count, os.WriteFile(path, buffer.Bytes(), 0644)
+}
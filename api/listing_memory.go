@@ -0,0 +1,50 @@
+package api
+
+import (
+	"sync"
+)
+
+// listingMemory remembers the most recently seen records of a live datasource by ID,
+// so that a market found by a query can be looked up again without an upstream API
+// that fetches single markets. Once full, the records remembered first are forgotten.
+type listingMemory struct {
+	mu      sync.Mutex
+	size    int
+	records map[string]FarmersMarketRecord
+	order   []string // IDs in the order they were first remembered
+}
+
+// newListingMemory returns a listingMemory remembering at most size records
+func newListingMemory(size int) *listingMemory {
+	return &listingMemory{size: size, records: make(map[string]FarmersMarketRecord)}
+}
+
+// remember stores records, replacing older copies of the same markets
+func (memory *listingMemory) remember(records []FarmersMarketRecord) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	for _, record := range records {
+		if _, ok := memory.records[record.ID]; !ok {
+			memory.order = append(memory.order, record.ID)
+		}
+
+		record.Distance = -1
+		record.DistanceUnits = ""
+		memory.records[record.ID] = record
+	}
+
+	for len(memory.order) > memory.size {
+		delete(memory.records, memory.order[0])
+		memory.order = memory.order[1:]
+	}
+}
+
+// recall returns the remembered record with the given ID
+func (memory *listingMemory) recall(id string) (FarmersMarketRecord, bool) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	record, ok := memory.records[id]
+	return record, ok
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// ErrMarketNotFound is returned when no market has the requested ID
+var ErrMarketNotFound = errors.New("market not found")
+
+// marketIDSeparator separates the datasource from the ID of the market within it
+const marketIDSeparator = ":"
+
+// MarketID returns the ID of the market with the given ID within a datasource,
+// e.g. "usda:312052". IDs are namespaced by datasource since the same market may
+// be listed by several datasources under different IDs.
+func MarketID(datasource string, localID string) string {
+	return datasource + marketIDSeparator + localID
+}
+
+// SplitMarketID returns the datasource and the ID within the datasource of a MarketID
+func SplitMarketID(id string) (string, string, error) {
+	datasource, localID, ok := strings.Cut(id, marketIDSeparator)
+	if !ok || datasource == "" || localID == "" {
+		return "", "", fmt.Errorf("%w: malformed id %q", ErrMarketNotFound, id)
+	}
+
+	return datasource, localID, nil
+}
+
+// hashedMarketID returns a MarketID for a market whose datasource does not give it
+// an ID. It is a hash of the name and the location of the market, so it stays the
+// same across refreshes for as long as neither of them changes upstream.
+func hashedMarketID(datasource string, name string, location geography.HaversinePoint) string {
+	// About a meter of precision, so that the ID survives the coordinates being rounded
+	key := fmt.Sprintf("%s|%.5f|%.5f", strings.ToLower(strings.TrimSpace(name)), location.Latitude, location.Longitude)
+
+	hash := sha256.Sum256([]byte(key))
+
+	return MarketID(datasource, hex.EncodeToString(hash[:8]))
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarketID(t *testing.T) {
+	datasource, localID, err := SplitMarketID(MarketID("usda", "312052"))
+	require.Nil(t, err)
+	require.Equal(t, "usda", datasource)
+	require.Equal(t, "312052", localID)
+
+	for _, id := range []string{"", "usda", "usda:", ":312052"} {
+		_, _, err := SplitMarketID(id)
+		require.ErrorIs(t, err, ErrMarketNotFound, id)
+	}
+
+	// Hashed IDs only depend on the name and the location
+	id := hashedMarketID("newyork", "Albany County Farmers' Market", albany)
+	require.Equal(t, id, hashedMarketID("newyork", " albany county farmers' market", albany))
+	require.NotEqual(t, id, hashedMarketID("newyork", "Albany County Farmers' Market", upperEastSide))
+	require.NotEqual(t, id, hashedMarketID("newyork", "Troy Waterfront Farmers Market", albany))
+}
+
+func TestCachedDatasetMarket(t *testing.T) {
+	records := []FarmersMarketRecord{
+		{ID: "test:albany", Name: "Albany County Farmers' Market", Location: albany, Distance: -1},
+		{ID: "test:ues", Name: "82nd Street Greenmarket", Location: upperEastSide, Distance: -1},
+	}
+
+	dataset := newCachedDataset(func(ctx context.Context) ([]FarmersMarketRecord, error) {
+		return records, nil
+	})
+
+	ctx := context.Background()
+
+	// Looking a market up does not carry over the distance of an earlier query
+	_, err := dataset.nearestN(ctx, 1, albany, Filter{})
+	require.Nil(t, err)
+
+	found, err := dataset.market(ctx, "test:ues")
+	require.Nil(t, err)
+	require.Equal(t, "82nd Street Greenmarket", found.Name)
+	require.Equal(t, float64(-1), found.Distance)
+
+	_, err = dataset.market(ctx, "test:troy")
+	require.ErrorIs(t, err, ErrMarketNotFound)
+}
+
+func TestUSDAMarket(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"data": [{"listing_id": "312052", "listing_name": "Potsdam Farmers Market", "location_x": "-73.98", "location_y": "40.78"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	client.BaseURL = server.URL
+
+	fmApi, err := NewUSDAFarmersMarketApi(Options{USDA: client, Geocoder: GeocoderChain{}})
+	require.Nil(t, err)
+
+	ctx := context.Background()
+
+	records, err := fmApi.NearestN(ctx, 1, geography.HaversinePoint{Latitude: 40.7, Longitude: -73.9}, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, "usda:312052@40.78000_-73.98000", records[0].ID)
+
+	// Markets returned by a query are remembered
+	found, err := fmApi.Market(ctx, records[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, "Potsdam Farmers Market", found.Name)
+	require.Equal(t, float64(-1), found.Distance)
+	require.Equal(t, 1, requests)
+
+	// Others are asked for around the location in their ID, e.g. after a restart
+	fmApi, err = NewUSDAFarmersMarketApi(Options{USDA: client, Geocoder: GeocoderChain{}})
+	require.Nil(t, err)
+
+	found, err = fmApi.Market(ctx, records[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, "Potsdam Farmers Market", found.Name)
+	require.Equal(t, float64(-1), found.Distance)
+	require.Equal(t, 2, requests)
+
+	for _, id := range []string{"usda:312052", "usda:312052@north_west", "usda:312052@95.00000_-73.98000", "usda:42@40.78000_-73.98000"} {
+		_, err = fmApi.Market(ctx, id)
+		require.ErrorIs(t, err, ErrMarketNotFound, id)
+	}
+}
+
+func TestListingMemoryForgetsOldestListings(t *testing.T) {
+	memory := newListingMemory(2)
+	memory.remember([]FarmersMarketRecord{{ID: "a"}, {ID: "b"}})
+	memory.remember([]FarmersMarketRecord{{ID: "b"}, {ID: "c"}})
+
+	_, ok := memory.recall("a")
+	require.False(t, ok)
+
+	for _, id := range []string{"b", "c"} {
+		_, ok := memory.recall(id)
+		require.True(t, ok, id)
+	}
+}
@@ -0,0 +1,596 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// MergedDatasourceName is the name of the datasource merging the others
+const MergedDatasourceName = "all"
+
+// DefaultMergedDatasources are the datasources the "all" datasource merges. For the
+// fields without an authoritative datasource, values are taken from the first of them
+// that has one.
+var DefaultMergedDatasources = []string{"newyork", "usda"}
+
+// MergedField is a group of fields of a FarmersMarketRecord that are always taken
+// together from the same record when records are merged
+type MergedField string
+
+const (
+	MergedName            MergedField = "name"             // Name
+	MergedDescription     MergedField = "description"      // Description
+	MergedAddress         MergedField = "address"          // Address
+	MergedLocation        MergedField = "location"         // Location, and the Distance to it
+	MergedWebsite         MergedField = "website"          // Website
+	MergedContact         MergedField = "contact"          // Contact
+	MergedSocialMedia     MergedField = "social_media"     // SocialMedia
+	MergedImage           MergedField = "image"            // ImageURL
+	MergedLastUpdated     MergedField = "last_updated"     // LastUpdated
+	MergedOperationHours  MergedField = "operation_hours"  // OperationHours and OperationSchedule
+	MergedOperationSeason MergedField = "operation_season" // OperationSeason, SeasonStart and SeasonEnd
+	MergedSeasons         MergedField = "seasons"          // OperationMonthsCode and Seasons
+	MergedSnap            MergedField = "snap"             // SnapStatus and FarmersMarketNutritionProgram
+)
+
+// DefaultFieldAuthority lists the datasources the "all" datasource takes each field from,
+// in order of preference, when a market is listed by several of them. New York State
+// knows about SNAP and when its markets are open, the USDA has richer listings.
+var DefaultFieldAuthority = map[MergedField][]string{
+	MergedDescription:     {"usda"},
+	MergedSocialMedia:     {"usda"},
+	MergedImage:           {"usda"},
+	MergedLastUpdated:     {"usda"},
+	MergedOperationHours:  {"newyork"},
+	MergedOperationSeason: {"newyork"},
+	MergedSeasons:         {"newyork"},
+	MergedSnap:            {"newyork"},
+}
+
+// mergedFieldCopiers copy a field from a record into a merged record, and return false
+// if the record has no value for it. Booleans only have a value if the datasource of
+// the record has the data for them.
+var mergedFieldCopiers = map[MergedField]func(merged *FarmersMarketRecord, from FarmersMarketRecord, capabilities DatasourceCapabilities) bool{
+	MergedName: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.Name = from.Name
+		return from.Name != ""
+	},
+	MergedDescription: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.Description = from.Description
+		return from.Description != ""
+	},
+	MergedAddress: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.Address = from.Address
+		return from.Address != FarmersMarketAddress{}
+	},
+	MergedLocation: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.Location = from.Location
+		merged.Distance = from.Distance
+		merged.DistanceUnits = from.DistanceUnits
+		return true
+	},
+	MergedWebsite: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.Website = from.Website
+		return from.Website != ""
+	},
+	MergedContact: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.Contact = from.Contact
+		return from.Contact != nil
+	},
+	MergedSocialMedia: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.SocialMedia = from.SocialMedia
+		return from.SocialMedia != nil
+	},
+	MergedImage: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.ImageURL = from.ImageURL
+		return from.ImageURL != ""
+	},
+	MergedLastUpdated: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.LastUpdated = from.LastUpdated
+		return from.LastUpdated != nil
+	},
+	MergedOperationHours: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.OperationHours = from.OperationHours
+		merged.OperationSchedule = from.OperationSchedule
+		return from.OperationHours != ""
+	},
+	MergedOperationSeason: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.OperationSeason = from.OperationSeason
+		merged.SeasonStart = from.SeasonStart
+		merged.SeasonEnd = from.SeasonEnd
+		return from.OperationSeason != ""
+	},
+	MergedSeasons: func(merged *FarmersMarketRecord, from FarmersMarketRecord, _ DatasourceCapabilities) bool {
+		merged.OperationMonthsCode = from.OperationMonthsCode
+		merged.Seasons = from.Seasons
+		return from.Seasons != 0
+	},
+	MergedSnap: func(merged *FarmersMarketRecord, from FarmersMarketRecord, capabilities DatasourceCapabilities) bool {
+		merged.SnapStatus = from.SnapStatus
+		merged.FarmersMarketNutritionProgram = from.FarmersMarketNutritionProgram
+		return capabilities.HasSnapData
+	},
+}
+
+// The thresholds for two records of different datasources to describe the same market
+const (
+	mergeDistance         = 250 // meters, at most between the two locations
+	mergeNameSimilarity   = 0.6 // at least between the names, or
+	mergeStreetSimilarity = 0.8 // at least between the street addresses
+)
+
+// mergedNearestNFactor is how many times more markets than requested NearestN asks
+// every member for, and asks again for if that is not enough
+const mergedNearestNFactor = 4
+
+// minMetersPerDegreeLatitude is less than the length of a degree of latitude anywhere
+// on Earth, so that records too far apart to be merged are skipped cheaply
+const minMetersPerDegreeLatitude = 110_000
+
+// mergedMember is a datasource merged by a MergedFarmersMarketApi
+type mergedMember struct {
+	name         string
+	api          FarmersMarketApi
+	capabilities DatasourceCapabilities
+}
+
+// MergedFarmersMarketApi is the implementation of the FarmersMarketApi that queries
+// several datasources and merges the records describing the same market: records of
+// different datasources that are close to each other and have a similar name or
+// street address. Each field of a merged record is taken from the datasource that is
+// authoritative for it.
+type MergedFarmersMarketApi struct {
+	name         string
+	members      []mergedMember
+	capabilities DatasourceCapabilities // what at least one member has
+	preferences  map[MergedField][]int  // indices of the members to take each field from, in order
+	geocoder     Geocoder
+	metricSpace  geography.MetricSpace
+}
+
+func init() {
+	RegisterDatasource(Datasource{
+		Name:        MergedDatasourceName,
+		Title:       "All Datasources",
+		Coverage:    "Farmers' Markets in every other datasource, merging the markets listed by more than one of them",
+		Attribution: "Combines the data of the other datasources, see their attribution.",
+		// Those of the datasources merged once constructed, see Capabilities
+		Capabilities: DatasourceCapabilities{
+			HasSnapData:        true,
+			HasOperationHours:  true,
+			HasOperationSeason: true,
+			HasOperationMonths: true,
+		},
+		Composite: true,
+		New: func(options Options) (FarmersMarketApi, error) {
+			// Only the datasources being served are merged
+			var datasources []string
+			for _, name := range DefaultMergedDatasources {
+				if options.serves(name) {
+					datasources = append(datasources, name)
+				}
+			}
+
+			return NewMergedFarmersMarketApi(options, datasources, DefaultFieldAuthority)
+		},
+	})
+}
+
+// NewMergedFarmersMarketApi returns a pointer to a freshly constructed Farmers Market Api
+// merging the given datasources, those in options.Apis or else constructed with options.
+// authority lists the datasources to take each field from, see DefaultFieldAuthority.
+// The Geocoder must be set in options.
+func NewMergedFarmersMarketApi(options Options, datasources []string, authority map[MergedField][]string) (*MergedFarmersMarketApi, error) {
+	if options.Geocoder == nil {
+		return nil, fmt.Errorf("geocoder not configured")
+	}
+
+	if len(datasources) == 0 {
+		return nil, fmt.Errorf("no datasources to merge")
+	}
+
+	fmApi := &MergedFarmersMarketApi{
+		name:         MergedDatasourceName,
+		capabilities: DatasourceCapabilities{Live: true},
+		preferences:  make(map[MergedField][]int),
+		geocoder:     options.Geocoder,
+		metricSpace:  geography.DefaultHaversineMetricSpace,
+	}
+
+	for _, name := range datasources {
+		datasource, ok := LookupDatasource(name)
+		if !ok || datasource.Composite {
+			return nil, fmt.Errorf("cannot merge datasource %s", name)
+		}
+
+		memberApi, err := options.datasourceApi(datasource)
+		if err != nil {
+			return nil, fmt.Errorf("could not load datasource %s: %w", name, err)
+		}
+
+		capabilities := datasource.CapabilitiesOf(memberApi)
+		fmApi.members = append(fmApi.members, mergedMember{name: name, api: memberApi, capabilities: capabilities})
+
+		fmApi.capabilities.HasSnapData = fmApi.capabilities.HasSnapData || capabilities.HasSnapData
+		fmApi.capabilities.HasOperationHours = fmApi.capabilities.HasOperationHours || capabilities.HasOperationHours
+		fmApi.capabilities.HasOperationSeason = fmApi.capabilities.HasOperationSeason || capabilities.HasOperationSeason
+		fmApi.capabilities.HasOperationMonths = fmApi.capabilities.HasOperationMonths || capabilities.HasOperationMonths
+		fmApi.capabilities.Live = fmApi.capabilities.Live && capabilities.Live
+	}
+
+	// The authoritative members first, then the others in the order they were given.
+	// Datasources that are not merged are not authoritative for anything.
+	for field := range mergedFieldCopiers {
+		var preference []int
+		for _, name := range authority[field] {
+			if idx := fmApi.memberIndex(name); idx >= 0 && !slices.Contains(preference, idx) {
+				preference = append(preference, idx)
+			}
+		}
+
+		for idx := range fmApi.members {
+			if !slices.Contains(preference, idx) {
+				preference = append(preference, idx)
+			}
+		}
+
+		fmApi.preferences[field] = preference
+	}
+
+	return fmApi, nil
+}
+
+// memberIndex returns the index of the member with the given name, or -1
+func (fmApi *MergedFarmersMarketApi) memberIndex(name string) int {
+	return slices.IndexFunc(fmApi.members, func(member mergedMember) bool { return member.name == name })
+}
+
+// Capabilities returns what at least one of the merged datasources has. It is only
+// Live if every one of them is.
+func (fmApi *MergedFarmersMarketApi) Capabilities() DatasourceCapabilities {
+	return fmApi.capabilities
+}
+
+// Refresh refreshes every merged datasource. The service refreshes the datasources it
+// serves on their own instead, see Datasource.Composite.
+func (fmApi *MergedFarmersMarketApi) Refresh(ctx context.Context) error {
+	var errs []error
+	for _, member := range fmApi.members {
+		if err := member.api.Refresh(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("refreshing %s: %w", member.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// query runs query on every member at the same time and merges the records they
+// return, in ascending order of distance. Members that fail are left out, it only
+// fails if all of them do.
+func (fmApi *MergedFarmersMarketApi) query(query func(member FarmersMarketApi) ([]FarmersMarketRecord, error)) ([]FarmersMarketRecord, error) {
+	results, err := fmApi.queryMembers(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return fmApi.merge(results), nil
+}
+
+// queryMembers runs query on every member at the same time and returns the records
+// of each, by member index. Members that fail have no records, it only fails if all
+// of them do.
+func (fmApi *MergedFarmersMarketApi) queryMembers(query func(member FarmersMarketApi) ([]FarmersMarketRecord, error)) ([][]FarmersMarketRecord, error) {
+	results := make([][]FarmersMarketRecord, len(fmApi.members))
+	errs := make([]error, len(fmApi.members))
+
+	var wg sync.WaitGroup
+	for idx, member := range fmApi.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			results[idx], errs[idx] = query(member.api)
+			if errs[idx] != nil {
+				errs[idx] = fmt.Errorf("%s: %w", member.name, errs[idx])
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for idx := range errs {
+		if errs[idx] != nil {
+			results[idx] = nil
+			failed++
+		}
+	}
+
+	if failed == len(fmApi.members) {
+		return nil, errors.Join(errs...)
+	}
+
+	return results, nil
+}
+
+// mergeCandidate is a record being merged, with the normalized text it is compared by
+type mergeCandidate struct {
+	record FarmersMarketRecord
+	name   string
+	street string
+}
+
+func newMergeCandidate(record FarmersMarketRecord) mergeCandidate {
+	return mergeCandidate{record: record, name: normalizeMarketName(record.Name), street: normalizeStreet(record.Address.Street)}
+}
+
+// matchScore returns how alike two records are, and false if they do not describe the same market
+func (fmApi *MergedFarmersMarketApi) matchScore(a mergeCandidate, b mergeCandidate) (float64, bool) {
+	if math.Abs(a.record.Location.Latitude-b.record.Location.Latitude)*minMetersPerDegreeLatitude > mergeDistance {
+		return 0, false
+	}
+
+	distance, err := fmApi.metricSpace.Distance(a.record.HaversinePoint(), b.record.HaversinePoint())
+	if err != nil || distance > mergeDistance {
+		return 0, false
+	}
+
+	name := similarity(a.name, b.name)
+	street := similarity(a.street, b.street)
+
+	if name < mergeNameSimilarity && street < mergeStreetSimilarity {
+		return 0, false
+	}
+
+	return name + street - distance/mergeDistance, true
+}
+
+// mergeCluster is the records of the members describing the same market
+type mergeCluster struct {
+	candidates []*mergeCandidate // by member index, nil if the member does not list the market
+	first      *mergeCandidate   // the record the others are compared to
+}
+
+// merge merges the records of every member, given by member index, into one record
+// per market, in ascending order of distance
+func (fmApi *MergedFarmersMarketApi) merge(results [][]FarmersMarketRecord) []FarmersMarketRecord {
+	var clusters []*mergeCluster
+
+	for memberIdx, records := range results {
+		// Only compare to the markets of the members before, a datasource does not
+		// list the same market twice
+		previous := len(clusters)
+
+		for _, record := range records {
+			candidate := newMergeCandidate(record)
+
+			var best *mergeCluster
+			bestScore := math.Inf(-1)
+
+			for _, cluster := range clusters[:previous] {
+				if cluster.candidates[memberIdx] != nil {
+					continue
+				}
+
+				if score, ok := fmApi.matchScore(*cluster.first, candidate); ok && score > bestScore {
+					best, bestScore = cluster, score
+				}
+			}
+
+			if best == nil {
+				best = &mergeCluster{candidates: make([]*mergeCandidate, len(fmApi.members)), first: &candidate}
+				clusters = append(clusters, best)
+			}
+
+			best.candidates[memberIdx] = &candidate
+		}
+	}
+
+	merged := make([]FarmersMarketRecord, 0, len(clusters))
+	for _, cluster := range clusters {
+		merged = append(merged, fmApi.mergeRecords(cluster))
+	}
+
+	slices.SortStableFunc(merged, func(a, b FarmersMarketRecord) int {
+		return cmp.Or(cmp.Compare(a.Distance, b.Distance), strings.Compare(a.ID, b.ID))
+	})
+
+	return merged
+}
+
+// mergeRecords returns the record merging the records of a cluster
+func (fmApi *MergedFarmersMarketApi) mergeRecords(cluster *mergeCluster) FarmersMarketRecord {
+	var merged FarmersMarketRecord
+
+	for field, copyField := range mergedFieldCopiers {
+		for _, memberIdx := range fmApi.preferences[field] {
+			candidate := cluster.candidates[memberIdx]
+			if candidate != nil && copyField(&merged, candidate.record, fmApi.members[memberIdx].capabilities) {
+				break
+			}
+		}
+	}
+
+	// The ID of the market in the first member listing it, so that it stays the same
+	// for as long as that member lists it
+	for _, candidate := range cluster.candidates {
+		if candidate == nil {
+			continue
+		}
+
+		if merged.ID == "" {
+			merged.ID = MarketID(fmApi.name, candidate.record.ID)
+		}
+
+		merged.MergedFrom = append(merged.MergedFrom, candidate.record.ID)
+	}
+
+	return merged
+}
+
+func (fmApi *MergedFarmersMarketApi) NearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error) {
+	if err := filter.supportedBy(fmApi.name, fmApi.capabilities); err != nil {
+		return nil, err
+	}
+
+	// The only negative value we accept is -1
+	if n < -1 {
+		return nil, fmt.Errorf("invalid value for n")
+	}
+
+	// Ask every member for a few more markets than requested, for the markets merged
+	// together or filtered out
+	want := -1
+	if n != -1 && n <= math.MaxInt/mergedNearestNFactor {
+		want = mergedNearestNFactor * max(n, 1)
+	}
+
+	// Members finding fewer markets than asked for is expected, falling short of n is
+	// reported below
+	memberCtx, _ := WithQueryReport(ctx)
+
+	for {
+		// The fields filtered on may come from another member, so filter after merging
+		results, err := fmApi.queryMembers(func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+			return member.NearestN(memberCtx, want, location, Filter{})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Markets further than the last one a member returned may have been merged
+		// with one it left out, only those closer are complete
+		cutoff := math.Inf(1)
+		for _, memberRecords := range results {
+			if want != -1 && len(memberRecords) >= want {
+				cutoff = min(cutoff, memberRecords[len(memberRecords)-1].Distance)
+			}
+		}
+
+		records := []FarmersMarketRecord{}
+		for _, record := range fmApi.merge(results) {
+			if record.Distance <= cutoff && filter.Matches(record) {
+				records = append(records, record)
+			}
+		}
+
+		if n == -1 {
+			return records, nil
+		}
+
+		if n <= len(records) {
+			return records[:n], nil
+		}
+
+		// Every member returned all the markets it has
+		if math.IsInf(cutoff, 1) {
+			reportWarning(ctx, "found %d of the %d markets requested", len(records), n)
+			return records, nil
+		}
+
+		// Ask for more markets, or for all of them once that is as many
+		if want > math.MaxInt/mergedNearestNFactor {
+			want = -1
+		} else {
+			want *= mergedNearestNFactor
+		}
+	}
+}
+
+func (fmApi *MergedFarmersMarketApi) NearestNByZipCode(ctx context.Context, n int, zipcode string, filter Filter) ([]FarmersMarketRecord, error) {
+	location, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return fmApi.NearestN(ctx, n, location, filter)
+}
+
+func (fmApi *MergedFarmersMarketApi) WithinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error) {
+	if distance < 0 || math.IsNaN(distance) {
+		return nil, fmt.Errorf("invalid value for distance")
+	}
+
+	return fmApi.query(func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.WithinRadius(ctx, location, distance)
+	})
+}
+
+func (fmApi *MergedFarmersMarketApi) WithinRadiusByZipCode(ctx context.Context, zipcode string, distance float64) ([]FarmersMarketRecord, error) {
+	location, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return fmApi.WithinRadius(ctx, location, distance)
+}
+
+func (fmApi *MergedFarmersMarketApi) WithinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error) {
+	if _, err := geography.NewBoundingBox(southWest, northEast); err != nil {
+		return nil, fmt.Errorf("within bounds: %w", err)
+	}
+
+	merged, err := fmApi.query(func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		return member.WithinBounds(ctx, southWest, northEast, -1)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limitRecords(merged, limit)
+}
+
+// Market looks the market up in the member its ID comes from, and merges it with
+// the markets of the other members around it
+func (fmApi *MergedFarmersMarketApi) Market(ctx context.Context, id string) (FarmersMarketRecord, error) {
+	datasource, memberID, err := SplitMarketID(id)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	memberName, _, err := SplitMarketID(memberID)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	memberIdx := fmApi.memberIndex(memberName)
+	if datasource != fmApi.name || memberIdx < 0 {
+		return FarmersMarketRecord{}, fmt.Errorf("%w: %s", ErrMarketNotFound, id)
+	}
+
+	record, err := fmApi.members[memberIdx].api.Market(ctx, memberID)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	merged, err := fmApi.query(func(member FarmersMarketApi) ([]FarmersMarketRecord, error) {
+		if member == fmApi.members[memberIdx].api {
+			return []FarmersMarketRecord{record}, nil
+		}
+
+		return member.WithinRadius(ctx, record.HaversinePoint(), mergeDistance)
+	})
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	for _, market := range merged {
+		if slices.Contains(market.MergedFrom, memberID) {
+			market.Distance = -1
+			market.DistanceUnits = ""
+			return market, nil
+		}
+	}
+
+	return FarmersMarketRecord{}, fmt.Errorf("%w: %s", ErrMarketNotFound, id)
+}
+
+// Verify that MergedFarmersMarketApi implements FarmersMarketApi
+var _ FarmersMarketApi = (*MergedFarmersMarketApi)(nil)
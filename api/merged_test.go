@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mergedTestNewYorkResponse = `[
+	{"market_name": "Albany County Farmers' Market", "address_line_1": "51 S. Pearl St", "city": "Albany", "state": "NY",
+	 "operation_hours": "Sun 10am-2pm", "snap_status": "Y", "fmnp": "N", "latitude": "42.64819", "longitude": "-73.75383"},
+	{"market_name": "Delmar Farmers Market", "address_line_1": "427 Kenwood Ave", "city": "Delmar", "state": "NY",
+	 "snap_status": "N", "fmnp": "N", "latitude": "42.62185", "longitude": "-73.83229"}
+]`
+
+const mergedTestUSDAResponse = `{"data": [
+	{"listing_id": "1", "listing_name": "Albany County Farmers Market", "brief_desc": "Fresh produce downtown",
+	 "location_street": "51 South Pearl Street", "location_x": "-73.7537", "location_y": "42.6483"},
+	{"listing_id": "2", "listing_name": "Lark Street Market", "location_street": "200 Lark St",
+	 "location_x": "-73.7641", "location_y": "42.6531"},
+	{"listing_id": "3", "listing_name": "Pearl Street Bazaar", "location_street": "60 N Pearl St",
+	 "location_x": "-73.7536", "location_y": "42.6490"}
+]}`
+
+// newTestMergedApi returns the "all" datasource over New York and USDA stand-ins
+// responding with the given bodies, or failing if the body is empty
+func newTestMergedApi(t *testing.T, newYorkResponse string, usdaResponse string) *MergedFarmersMarketApi {
+	upstream := func(body string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body == "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	// Failures are simulated, retrying them would only slow the tests down
+	newYork := NewNewYorkClient(nil)
+	newYork.BaseURL = upstream(newYorkResponse)
+
+	usda := NewUSDAClient("key", nil)
+	usda.BaseURL = upstream(usdaResponse)
+
+	fmApi, err := NewMergedFarmersMarketApi(Options{USDA: usda, NewYork: newYork, Geocoder: GeocoderChain{}}, DefaultMergedDatasources, DefaultFieldAuthority)
+	require.Nil(t, err)
+
+	return fmApi
+}
+
+func TestMergedNearestN(t *testing.T) {
+	fmApi := newTestMergedApi(t, mergedTestNewYorkResponse, mergedTestUSDAResponse)
+
+	records, err := fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+
+	var names []string
+	for _, record := range records {
+		names = append(names, record.Name)
+	}
+
+	// The two listings of the Albany County Farmers' Market are merged, the bazaar
+	// next to it is another market
+	require.Equal(t, []string{"Pearl Street Bazaar", "Albany County Farmers' Market", "Lark Street Market", "Delmar Farmers Market"}, names)
+
+	albanyMarket := records[1]
+	require.Equal(t, []string{hashedMarketID("newyork", "Albany County Farmers' Market", albanyMarket.Location), "usda:1@42.64830_-73.75370"}, albanyMarket.MergedFrom)
+	require.Equal(t, MarketID("all", albanyMarket.MergedFrom[0]), albanyMarket.ID)
+
+	// SNAP and hours come from New York, the description from the USDA
+	require.True(t, albanyMarket.SnapStatus)
+	require.Equal(t, "Sun 10am-2pm", albanyMarket.OperationHours)
+	require.Equal(t, "Fresh produce downtown", albanyMarket.Description)
+
+	// Filters apply to the merged records, and finding fewer than n is not an error
+	ctx, report := WithQueryReport(context.Background())
+	records, err = fmApi.NearestN(ctx, 5, albany, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Equal(t, []string{"found 1 of the 5 markets requested"}, report.Warnings())
+	require.Equal(t, 1, len(records))
+	require.Equal(t, albanyMarket.ID, records[0].ID)
+
+	found, err := fmApi.Market(context.Background(), albanyMarket.ID)
+	require.Nil(t, err)
+	require.Equal(t, albanyMarket.MergedFrom, found.MergedFrom)
+	require.Equal(t, "Fresh produce downtown", found.Description)
+
+	_, err = fmApi.Market(context.Background(), "all:usda:42")
+	require.ErrorIs(t, err, ErrMarketNotFound)
+}
+
+func TestMergedNearestNAsksForMore(t *testing.T) {
+	// Ten markets going north from Albany, only the ninth of which accepts SNAP
+	var markets []string
+	for idx := 0; idx < 10; idx++ {
+		snap := "N"
+		if idx == 8 {
+			snap = "Y"
+		}
+
+		markets = append(markets, fmt.Sprintf(`{"market_name": "Market %d", "snap_status": "%s", "fmnp": "N", "latitude": "%f", "longitude": "-73.75383"}`,
+			idx, snap, 42.65+0.01*float64(idx)))
+	}
+
+	fmApi := newTestMergedApi(t, "["+strings.Join(markets, ",")+"]", "")
+
+	records, err := fmApi.NearestN(context.Background(), 2, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "Market 0", records[0].Name)
+	require.Equal(t, "Market 1", records[1].Name)
+
+	// The markets first asked for do not accept SNAP, the others are asked for then
+	ctx, report := WithQueryReport(context.Background())
+	records, err = fmApi.NearestN(ctx, 1, albany, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Empty(t, report.Warnings())
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Market 8", records[0].Name)
+}
+
+func TestMergedNearestNWithoutAMember(t *testing.T) {
+	// The USDA failing leaves the markets of New York
+	fmApi := newTestMergedApi(t, mergedTestNewYorkResponse, "")
+
+	records, err := fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "51 S. Pearl St", records[0].Address.Street)
+
+	fmApi = newTestMergedApi(t, "", "")
+
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.NotNil(t, err)
+}
+
+func TestSimilarity(t *testing.T) {
+	require.Equal(t, 1.0, similarity("albany county", "albany county"))
+	require.Equal(t, 0.0, similarity("", "albany"))
+	require.Equal(t, 0.0, similarity("", ""))
+
+	// Two markets without a street address are not alike for it
+	require.Equal(t, 0.0, similarity(normalizeStreet(""), normalizeStreet("  ")))
+
+	require.Equal(t, "albany county", normalizeMarketName("The Albany County Farmers' Market"))
+	require.Equal(t, "51 s pearl st", normalizeStreet("51 South Pearl Street"))
+	require.Equal(t, normalizeStreet("51 S. Pearl St"), normalizeStreet("51 South Pearl Street"))
+
+	require.Greater(t, similarity(normalizeMarketName("Albany County Farmers Market"), normalizeMarketName("Albany Cnty Farmer's Market")), mergeNameSimilarity)
+	require.Less(t, similarity(normalizeMarketName("Albany County Farmers Market"), normalizeMarketName("Pearl Street Bazaar")), mergeNameSimilarity)
+}
@@ -1,21 +1,32 @@
 package api
 
 import (
+	"context"
 	"testing"
 
+	"github.com/jadidbourbaki/gofarm/geography"
 	"github.com/stretchr/testify/require"
 )
 
-func TestParseNewYorkFarmersMarketDatasetEmptyDataset(t *testing.T) {
+// parseNewYorkDataset converts a New York State dataset the way the datasource does
+func parseNewYorkDataset(dataset []byte) ([]FarmersMarketRecord, error) {
+	rows, err := ParseFileDataset(dataset, FileFormatJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return newYorkDatasourceOptions.Mapping.Records(context.Background(), "newyork", rows)
+}
+
+func TestParseNewYorkDatasetEmptyDataset(t *testing.T) {
 	// An empty dataset should not be an error.
-	emptyDatasetRaw := []byte("[]")
-	emptyDataset, err := ParseNewYorkFarmersMarketDataset(emptyDatasetRaw)
+	emptyDataset, err := parseNewYorkDataset([]byte("[]"))
 
 	require.Nil(t, err)
 	require.Equal(t, len(emptyDataset), 0)
 }
 
-func TestParseNewYorkFarmersMarketDatasetOneRecordDataset(t *testing.T) {
+func TestParseNewYorkDatasetOneRecordDataset(t *testing.T) {
 	// A one record dataset
 	oneRecordDatasetRaw := []byte(`[
 		{
@@ -40,35 +51,38 @@ func TestParseNewYorkFarmersMarketDatasetOneRecordDataset(t *testing.T) {
 			"longitude": "-73.75383"
 		}]`)
 
-	oneRecordDatasetExpectedRecord := NewYorkFarmersMarketRecord{
-		County:                        "Albany",
-		MarketName:                    "Albany County Farmers' Market",
-		Location:                      "51 S. Pearl St (in front of  MVP Arena)",
-		AddressLine1:                  "51 S. Pearl St",
-		City:                          "Albany",
-		StateCode:                     "NY",
-		Zip:                           "12207",
-		Contact:                       "Jevan Dollard",
-		Telephone:                     "5184652143",
-		MarketLink:                    NewYorkFarmersMarketLink{Url: "https://www.downtownalbany.org/albany-county-farmers-market"},
-		OperationHours:                "Sun 10am-2pm",
-		OperationSeason:               "July 14-September 29",
-		OperationMonthsCode:           "M",
-		FarmersMarketNutritionProgram: "N",
-		SnapStatus:                    "Y",
-		Latitude:                      "42.64819",
-		Longitude:                     "-73.75383",
-		latitudeFloat:                 42.64819,
-		longitudeFloat:                -73.75383,
-	}
+	location := geography.HaversinePoint{Latitude: 42.64819, Longitude: -73.75383}
+	start, end := MonthDay{Month: 7, Day: 14}, MonthDay{Month: 9, Day: 29}
 
-	oneRecordDataset, err := ParseNewYorkFarmersMarketDataset(oneRecordDatasetRaw)
+	oneRecordDataset, err := parseNewYorkDataset(oneRecordDatasetRaw)
 	require.Nil(t, err)
 	require.Equal(t, len(oneRecordDataset), 1)
-	require.Equal(t, oneRecordDataset[0], oneRecordDatasetExpectedRecord)
+
+	record := oneRecordDataset[0]
+	require.Equal(t, hashedMarketID("newyork", "Albany County Farmers' Market", location), record.ID)
+	require.Equal(t, "Albany County Farmers' Market", record.Name)
+	require.Equal(t, FarmersMarketAddress{Street: "51 S. Pearl St", City: "Albany", State: "NY", ZipCode: "12207"}, record.Address)
+	require.Equal(t, location, record.Location)
+	require.Equal(t, "https://www.downtownalbany.org/albany-county-farmers-market", record.Website)
+	require.Equal(t, &FarmersMarketContact{Name: "Jevan Dollard", Phone: "5184652143"}, record.Contact)
+	require.Nil(t, record.SocialMedia)
+	require.Equal(t, "Sun 10am-2pm", record.OperationHours)
+	require.NotNil(t, record.OperationSchedule)
+	require.Equal(t, &start, record.SeasonStart)
+	require.Equal(t, &end, record.SeasonEnd)
+	require.Equal(t, SeasonSet(Summer), record.Seasons)
+	require.True(t, record.SnapStatus)
+	require.False(t, record.FarmersMarketNutritionProgram)
+	require.Equal(t, -1.0, record.Distance)
+}
+
+func TestNewYorkDatasourceCapabilities(t *testing.T) {
+	datasource, ok := LookupDatasource("newyork")
+	require.True(t, ok)
+	require.Equal(t, DatasourceCapabilities{HasSnapData: true, HasOperationHours: true, HasOperationSeason: true, HasOperationMonths: true}, datasource.Capabilities)
 }
 
-func TestParseNewYorkFarmersMarketDatasetMultiRecordDataset(t *testing.T) {
+func TestParseNewYorkDatasetMultiRecordDataset(t *testing.T) {
 	// A multi record dataset
 	multiRecordDatasetRaw := []byte(`[
 		{
@@ -114,7 +128,9 @@ func TestParseNewYorkFarmersMarketDatasetMultiRecordDataset(t *testing.T) {
 		"longitude": "-73.9506"
 		}]`)
 
-	multiRecordDataset, err := ParseNewYorkFarmersMarketDataset(multiRecordDatasetRaw)
+	multiRecordDataset, err := parseNewYorkDataset(multiRecordDatasetRaw)
 	require.Nil(t, err)
 	require.Equal(t, len(multiRecordDataset), 2)
+	require.True(t, multiRecordDataset[1].FarmersMarketNutritionProgram)
+	require.Equal(t, SeasonSet(YearRound), multiRecordDataset[1].Seasons)
 }
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Weekday is a time.Weekday that is marshalled as its name, e.g. "Sunday"
+type Weekday time.Weekday
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (day Weekday) MarshalText() ([]byte, error) {
+	return []byte(time.Weekday(day).String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (day *Weekday) UnmarshalText(text []byte) error {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if weekday.String() == string(text) {
+			*day = Weekday(weekday)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown weekday: %q", text)
+}
+
+// TimeOfDay is a local time of day in minutes since midnight. It is marshalled
+// as hours and minutes on a 24 hour clock, e.g. "14:30". Midnight at the end of
+// the day is "24:00".
+type TimeOfDay int
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (timeOfDay TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%02d:%02d", timeOfDay/60, timeOfDay%60)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (timeOfDay *TimeOfDay) UnmarshalText(text []byte) error {
+	hoursString, minutesString, ok := strings.Cut(string(text), ":")
+	if !ok {
+		return fmt.Errorf("time of day malformatted: %q", text)
+	}
+
+	hours, err := strconv.Atoi(hoursString)
+	if err != nil {
+		return fmt.Errorf("time of day malformatted: %w", err)
+	}
+
+	minutes, err := strconv.Atoi(minutesString)
+	if err != nil {
+		return fmt.Errorf("time of day malformatted: %w", err)
+	}
+
+	*timeOfDay = TimeOfDay(hours*60 + minutes)
+
+	return nil
+}
+
+// OpeningHours is the time a market opens and closes on one day of the week
+type OpeningHours struct {
+	Day    Weekday   `json:"day"`
+	Opens  TimeOfDay `json:"opens"`
+	Closes TimeOfDay `json:"closes"`
+}
+
+// WeeklySchedule is the opening hours of a market, sorted by day and time.
+// A market may open more than once on the same day.
+type WeeklySchedule []OpeningHours
+
+// IsOpen returns true if the market is open at t, which must be in the time
+// zone of the market
+func (schedule WeeklySchedule) IsOpen(t time.Time) bool {
+	now := TimeOfDay(t.Hour()*60 + t.Minute())
+
+	for _, hours := range schedule {
+		if time.Weekday(hours.Day) == t.Weekday() && hours.Opens <= now && now < hours.Closes {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The building blocks of the operation hours regular expression
+const (
+	dayPattern     = `(?:monday|mon|tuesday|tues|tue|wednesday|wed|thursday|thurs|thur|thu|friday|fri|saturday|sat|sunday|sun)s?\b\.?`
+	timePattern    = `(?:noon|midnight|\d{1,2}(?::\d{2})?\s*(?:a\.?m\.?|p\.?m\.?)?)`
+	throughPattern = `\s*(?:-|–|—|to|thru|through|until|till)\s*`
+)
+
+// operationHoursPattern finds the days and the time ranges in operation hours,
+// from left to right
+var operationHoursPattern = regexp.MustCompile(`(?P<dayRange>` + dayPattern + throughPattern + dayPattern + `)` +
+	`|(?P<day>` + dayPattern + `)` +
+	`|(?P<everyDay>daily|every ?day|7 days|seven days)` +
+	`|(?P<weekdays>weekdays)` +
+	`|(?P<weekends>weekends?)` +
+	`|(?P<timeRange>` + timePattern + throughPattern + timePattern + `)`)
+
+var dayNamePattern = regexp.MustCompile(`(mon|tue|wed|thu|fri|sat|sun)`)
+var timeComponentsPattern = regexp.MustCompile(`(noon|midnight)|(\d{1,2})(?::(\d{2}))?\s*(?:(a)\.?m\.?|(p)\.?m\.?)?`)
+
+var dayAbbreviations = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseDays returns the days named in text, in order
+func parseDays(text string) []time.Weekday {
+	var days []time.Weekday
+	for _, name := range dayNamePattern.FindAllString(text, -1) {
+		days = append(days, dayAbbreviations[name])
+	}
+
+	return days
+}
+
+// clockTime is a time as written in operation hours, possibly without am or pm
+type clockTime struct {
+	hours, minutes int
+	meridiem       string // "a", "p" or empty if unknown
+}
+
+// timeOfDay converts the time on a 12 hour clock into a TimeOfDay
+func (clock clockTime) timeOfDay(meridiem string) TimeOfDay {
+	hours := clock.hours % 12
+	if meridiem == "p" {
+		hours += 12
+	}
+
+	return TimeOfDay(hours*60 + clock.minutes)
+}
+
+// parseTimeRange parses a time range such as "9:30am - 2pm" or "10-2". A time without
+// am or pm takes the one of the other time if that makes sense, otherwise market hours
+// are assumed: 7 to 11 are in the morning and 12 to 6 in the afternoon.
+func parseTimeRange(text string) (TimeOfDay, TimeOfDay, bool) {
+	matches := timeComponentsPattern.FindAllStringSubmatch(text, 2)
+	if len(matches) != 2 {
+		return 0, 0, false
+	}
+
+	var clocks [2]clockTime
+	for idx, match := range matches {
+		switch match[1] {
+		case "noon":
+			clocks[idx] = clockTime{hours: 12, meridiem: "p"}
+			continue
+		case "midnight":
+			clocks[idx] = clockTime{hours: 12, meridiem: "a"}
+			continue
+		}
+
+		clocks[idx].hours, _ = strconv.Atoi(match[2])
+		clocks[idx].minutes, _ = strconv.Atoi(match[3])
+		clocks[idx].meridiem = match[4] + match[5]
+
+		if clocks[idx].hours > 12 || clocks[idx].minutes > 59 {
+			return 0, 0, false
+		}
+	}
+
+	opening, closing := clocks[0], clocks[1]
+
+	switch {
+	case opening.meridiem == "" && closing.meridiem == "":
+		for _, clock := range []*clockTime{&opening, &closing} {
+			clock.meridiem = "a"
+			if clock.hours == 12 || clock.hours <= 6 {
+				clock.meridiem = "p"
+			}
+		}
+	case opening.meridiem == "":
+		opening.meridiem = closing.meridiem
+		if opening.timeOfDay(opening.meridiem) >= closing.timeOfDay(closing.meridiem) {
+			opening.meridiem = "a"
+		}
+	case closing.meridiem == "":
+		closing.meridiem = opening.meridiem
+		if opening.timeOfDay(opening.meridiem) >= closing.timeOfDay(closing.meridiem) {
+			closing.meridiem = "p"
+		}
+	}
+
+	opens := opening.timeOfDay(opening.meridiem)
+	closes := closing.timeOfDay(closing.meridiem)
+
+	// Closing at midnight is the end of the day, not the start
+	if closes == 0 {
+		closes = 24 * 60
+	}
+
+	if opens >= closes {
+		return 0, 0, false
+	}
+
+	return opens, closes, true
+}
+
+// ParseOperationHours parses free text operation hours such as "Sun 10am-2pm",
+// "Wed & Sat 8am-3pm", "Mon-Fri 9:30 AM - 1:30 PM" or "Tue 9am-1pm; Fri 3pm-7pm"
+// into a WeeklySchedule. A time range applies to the days named since the previous
+// time range, or the same days as the previous time range if no day was named in
+// between. Text that is not a day or a time range, such as the months a market is
+// open, is ignored. It returns an error if no opening hours are found.
+func ParseOperationHours(text string) (WeeklySchedule, error) {
+	lowerText := strings.ToLower(text)
+
+	var schedule WeeklySchedule
+	var days []time.Weekday
+	daysUsed := false
+
+	names := operationHoursPattern.SubexpNames()
+
+	for _, match := range operationHoursPattern.FindAllStringSubmatchIndex(lowerText, -1) {
+		for group := 1; group < len(names); group++ {
+			start, end := match[2*group], match[2*group+1]
+			if start < 0 {
+				continue
+			}
+
+			matched := lowerText[start:end]
+
+			if names[group] == "timeRange" {
+				// Dates such as 6/1-10/31 are not times
+				if (start > 0 && lowerText[start-1] == '/') || (end < len(lowerText) && lowerText[end] == '/') {
+					break
+				}
+
+				opens, closes, ok := parseTimeRange(matched)
+				if !ok {
+					break
+				}
+
+				for _, day := range days {
+					schedule = append(schedule, OpeningHours{Day: Weekday(day), Opens: opens, Closes: closes})
+				}
+
+				daysUsed = true
+				break
+			}
+
+			// A new list of days starts after a time range
+			if daysUsed {
+				days = nil
+				daysUsed = false
+			}
+
+			switch names[group] {
+			case "dayRange":
+				rangeDays := parseDays(matched)
+				for day := rangeDays[0]; ; day = (day + 1) % 7 {
+					days = append(days, day)
+					if day == rangeDays[len(rangeDays)-1] {
+						break
+					}
+				}
+			case "day":
+				days = append(days, parseDays(matched)...)
+			case "everyDay":
+				days = append(days, time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday)
+			case "weekdays":
+				days = append(days, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+			case "weekends":
+				days = append(days, time.Saturday, time.Sunday)
+			}
+
+			break
+		}
+	}
+
+	if len(schedule) == 0 {
+		return nil, fmt.Errorf("no opening hours found in %q", text)
+	}
+
+	slices.SortFunc(schedule, func(a, b OpeningHours) int {
+		if a.Day != b.Day {
+			return int(a.Day) - int(b.Day)
+		}
+
+		return int(a.Opens) - int(b.Opens)
+	})
+
+	return slices.Compact(schedule), nil
+}
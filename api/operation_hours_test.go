@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func hoursOn(day time.Weekday, opens string, closes string) OpeningHours {
+	hours := OpeningHours{Day: Weekday(day)}
+	if err := hours.Opens.UnmarshalText([]byte(opens)); err != nil {
+		panic(err)
+	}
+
+	if err := hours.Closes.UnmarshalText([]byte(closes)); err != nil {
+		panic(err)
+	}
+
+	return hours
+}
+
+func TestParseOperationHours(t *testing.T) {
+	for text, expected := range map[string]WeeklySchedule{
+		"Sun 10am-2pm":   {hoursOn(time.Sunday, "10:00", "14:00")},
+		"Sat 9am-2:30pm": {hoursOn(time.Saturday, "09:00", "14:30")},
+		"Wed & Sat 8am-3pm": {
+			hoursOn(time.Wednesday, "08:00", "15:00"),
+			hoursOn(time.Saturday, "08:00", "15:00"),
+		},
+		"Tuesday 9am-1pm; Friday 3pm-7pm": {
+			hoursOn(time.Tuesday, "09:00", "13:00"),
+			hoursOn(time.Friday, "15:00", "19:00"),
+		},
+		"Mon-Wed 9:30 AM - 1:30 PM": {
+			hoursOn(time.Monday, "09:30", "13:30"),
+			hoursOn(time.Tuesday, "09:30", "13:30"),
+			hoursOn(time.Wednesday, "09:30", "13:30"),
+		},
+		"Fri-Sun 11-2": {
+			hoursOn(time.Sunday, "11:00", "14:00"),
+			hoursOn(time.Friday, "11:00", "14:00"),
+			hoursOn(time.Saturday, "11:00", "14:00"),
+		},
+		"Thurs noon to 6 p.m. (6/1-10/31)": {hoursOn(time.Thursday, "12:00", "18:00")},
+		"Sat 8am-12pm, 1pm-4pm": {
+			hoursOn(time.Saturday, "08:00", "12:00"),
+			hoursOn(time.Saturday, "13:00", "16:00"),
+		},
+		"Weekends 10am-midnight": {
+			hoursOn(time.Sunday, "10:00", "24:00"),
+			hoursOn(time.Saturday, "10:00", "24:00"),
+		},
+	} {
+		schedule, err := ParseOperationHours(text)
+		require.Nil(t, err, text)
+		require.Equal(t, expected, schedule, text)
+	}
+
+	for _, text := range []string{"", "Call for hours", "Sat", "9am-2pm"} {
+		_, err := ParseOperationHours(text)
+		require.NotNil(t, err, text)
+	}
+}
+
+func TestWeeklyScheduleIsOpen(t *testing.T) {
+	schedule, err := ParseOperationHours("Sun 10am-2pm")
+	require.Nil(t, err)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.Nil(t, err)
+
+	// 2024-05-05 is a Sunday
+	require.True(t, schedule.IsOpen(time.Date(2024, 5, 5, 10, 0, 0, 0, newYork)))
+	require.True(t, schedule.IsOpen(time.Date(2024, 5, 5, 13, 59, 0, 0, newYork)))
+	require.False(t, schedule.IsOpen(time.Date(2024, 5, 5, 14, 0, 0, 0, newYork)))
+	require.False(t, schedule.IsOpen(time.Date(2024, 5, 6, 11, 0, 0, 0, newYork)))
+}
+
+func TestWeeklyScheduleJson(t *testing.T) {
+	schedule := WeeklySchedule{hoursOn(time.Sunday, "10:00", "14:00")}
+
+	scheduleJson, err := json.Marshal(schedule)
+	require.Nil(t, err)
+	require.Equal(t, `[{"day":"Sunday","opens":"10:00","closes":"14:00"}]`, string(scheduleJson))
+
+	var decoded WeeklySchedule
+	require.Nil(t, json.Unmarshal(scheduleJson, &decoded))
+	require.Equal(t, schedule, decoded)
+}
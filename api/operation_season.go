@@ -0,0 +1,163 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MonthDay is a day of the year without the year, such as the first or last day
+// of a market's season. It is marshalled as the month and the day, e.g. "07-14".
+type MonthDay struct {
+	Month time.Month
+	Day   int
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (monthDay MonthDay) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%02d-%02d", monthDay.Month, monthDay.Day)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (monthDay *MonthDay) UnmarshalText(text []byte) error {
+	parsed, err := time.Parse("01-02", string(text))
+	if err != nil {
+		return fmt.Errorf("month and day malformatted: %w", err)
+	}
+
+	*monthDay = MonthDay{Month: parsed.Month(), Day: parsed.Day()}
+
+	return nil
+}
+
+// ordinal orders days of the year, it is not the actual day of the year
+func (monthDay MonthDay) ordinal() int {
+	return int(monthDay.Month)*100 + monthDay.Day
+}
+
+// InSeason returns true if date, ignoring its year, is between start and end inclusive.
+// A season whose end is before its start wraps around the end of the year, e.g. a
+// winter market from November to March.
+func InSeason(start MonthDay, end MonthDay, date time.Time) bool {
+	day := MonthDay{Month: date.Month(), Day: date.Day()}.ordinal()
+
+	if start.ordinal() <= end.ordinal() {
+		return start.ordinal() <= day && day <= end.ordinal()
+	}
+
+	return day >= start.ordinal() || day <= end.ordinal()
+}
+
+var monthPattern = `(january|jan|february|feb|march|mar|april|apr|may|june|jun|july|jul|august|aug|september|sept|sep|october|oct|november|nov|december|dec)\b\.?`
+
+// seasonDatePattern finds dates such as "July 14", "Sept. 3rd, 2023", "October" or "6/1/2023"
+var seasonDatePattern = regexp.MustCompile(monthPattern + `(?:\s*(\d{1,2})(?:st|nd|rd|th)?\b)?(?:,?\s*\d{4})?` +
+	`|\b(\d{1,2})/(\d{1,2})(?:/\d{2,4})?\b`)
+
+// sameMonthEndPattern finds the end of a season within a single month, such as "-30" in "June 1-30"
+var sameMonthEndPattern = regexp.MustCompile(`^\s*(?:-|–|—|to|thru|through|until)\s*(\d{1,2})\b`)
+
+var yearRoundPattern = regexp.MustCompile(`year[\s-]*round|all year`)
+
+// daysInMonth returns the number of days in a month, counting February 29
+func daysInMonth(month time.Month) int {
+	// The day before the first of the next month, in a leap year
+	return time.Date(2024, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// newMonthDay returns the MonthDay if day is a day of the month. A day of 0 stands
+// for the first day of the month if first is true, or the last day otherwise.
+func newMonthDay(month time.Month, day int, first bool) (MonthDay, error) {
+	if month < time.January || month > time.December {
+		return MonthDay{}, fmt.Errorf("invalid month: %v", int(month))
+	}
+
+	if day == 0 {
+		day = 1
+		if !first {
+			day = daysInMonth(month)
+		}
+	}
+
+	if day < 1 || day > daysInMonth(month) {
+		return MonthDay{}, fmt.Errorf("invalid day of %s: %v", month, day)
+	}
+
+	return MonthDay{Month: month, Day: day}, nil
+}
+
+// parseMonthName returns the month for a full or abbreviated month name
+func parseMonthName(name string) time.Month {
+	for month := time.January; month <= time.December; month++ {
+		if strings.HasPrefix(strings.ToLower(month.String()), name[:3]) {
+			return month
+		}
+	}
+
+	return 0
+}
+
+// ParseOperationSeason parses free text operation seasons such as "July 14-September 29",
+// "June 1 - November 23, 2023", "May-October", "6/1-10/31" or "Year-round" into the first
+// and last days of the season. A month without a day stands for all of it. The season
+// may wrap around the end of the year, see InSeason.
+func ParseOperationSeason(text string) (MonthDay, MonthDay, error) {
+	lowerText := strings.ToLower(text)
+
+	if yearRoundPattern.MatchString(lowerText) {
+		return MonthDay{Month: time.January, Day: 1}, MonthDay{Month: time.December, Day: 31}, nil
+	}
+
+	var dates []MonthDay
+
+	for _, match := range seasonDatePattern.FindAllStringSubmatchIndex(lowerText, -1) {
+		group := func(idx int) string {
+			if match[2*idx] < 0 {
+				return ""
+			}
+
+			return lowerText[match[2*idx]:match[2*idx+1]]
+		}
+
+		var month time.Month
+		var day int
+
+		if group(1) != "" {
+			month = parseMonthName(group(1))
+			day, _ = strconv.Atoi(group(2))
+		} else {
+			monthNumber, _ := strconv.Atoi(group(3))
+			month = time.Month(monthNumber)
+			day, _ = strconv.Atoi(group(4))
+		}
+
+		date, err := newMonthDay(month, day, len(dates) == 0)
+		if err != nil {
+			return MonthDay{}, MonthDay{}, fmt.Errorf("could not parse season %q: %w", text, err)
+		}
+
+		dates = append(dates, date)
+
+		// A season within a single month, such as "June 1-30"
+		if len(dates) == 1 && group(2) != "" {
+			if end := sameMonthEndPattern.FindStringSubmatch(lowerText[match[1]:]); end != nil {
+				endDay, _ := strconv.Atoi(end[1])
+
+				date, err := newMonthDay(month, endDay, false)
+				if err != nil {
+					return MonthDay{}, MonthDay{}, fmt.Errorf("could not parse season %q: %w", text, err)
+				}
+
+				dates = append(dates, date)
+			}
+		}
+
+		if len(dates) == 2 {
+			return dates[0], dates[1], nil
+		}
+	}
+
+	return MonthDay{}, MonthDay{}, fmt.Errorf("no season found in %q", text)
+}
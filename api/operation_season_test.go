@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOperationSeason(t *testing.T) {
+	for text, expected := range map[string][2]MonthDay{
+		"July 14-September 29":       {{time.July, 14}, {time.September, 29}},
+		"June 1 - November 23, 2023": {{time.June, 1}, {time.November, 23}},
+		"Sept. 3rd to Oct 31st":      {{time.September, 3}, {time.October, 31}},
+		"May-October":                {{time.May, 1}, {time.October, 31}},
+		"November - February":        {{time.November, 1}, {time.February, 29}},
+		"6/1-10/31":                  {{time.June, 1}, {time.October, 31}},
+		"06/01/2023 - 10/31/2023":    {{time.June, 1}, {time.October, 31}},
+		"June 1-30":                  {{time.June, 1}, {time.June, 30}},
+		"Year-round":                 {{time.January, 1}, {time.December, 31}},
+		"Dec 1 - March 31 (indoors)": {{time.December, 1}, {time.March, 31}},
+	} {
+		start, end, err := ParseOperationSeason(text)
+		require.Nil(t, err, text)
+		require.Equal(t, expected[0], start, text)
+		require.Equal(t, expected[1], end, text)
+	}
+
+	for _, text := range []string{"", "Summer", "July 14", "June 31-July 4"} {
+		_, _, err := ParseOperationSeason(text)
+		require.NotNil(t, err, text)
+	}
+}
+
+func TestInSeason(t *testing.T) {
+	summer := [2]MonthDay{{time.July, 14}, {time.September, 29}}
+	winter := [2]MonthDay{{time.November, 1}, {time.March, 31}}
+
+	require.True(t, InSeason(summer[0], summer[1], time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC)))
+	require.True(t, InSeason(summer[0], summer[1], time.Date(2024, 9, 29, 0, 0, 0, 0, time.UTC)))
+	require.False(t, InSeason(summer[0], summer[1], time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)))
+
+	require.True(t, InSeason(winter[0], winter[1], time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)))
+	require.True(t, InSeason(winter[0], winter[1], time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)))
+	require.False(t, InSeason(winter[0], winter[1], time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestMonthDayJson(t *testing.T) {
+	monthDay := MonthDay{Month: time.July, Day: 4}
+
+	monthDayJson, err := json.Marshal(monthDay)
+	require.Nil(t, err)
+	require.Equal(t, `"07-04"`, string(monthDayJson))
+
+	var decoded MonthDay
+	require.Nil(t, json.Unmarshal(monthDayJson, &decoded))
+	require.Equal(t, monthDay, decoded)
+}
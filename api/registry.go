@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultDatasourceName is the datasource selected when a client has not
+// expressed a preference, e.g. the initial value of the dropdown on the home page.
+const DefaultDatasourceName = MergedDatasourceName
+
+// DatasourceCapabilities describes the optional data and behaviour a datasource offers
+type DatasourceCapabilities struct {
+	HasSnapData        bool `json:"has_snap_data"`        // SnapStatus and FarmersMarketNutritionProgram are populated
+	HasOperationHours  bool `json:"has_operation_hours"`  // OperationHours and OperationSchedule are populated
+	HasOperationSeason bool `json:"has_operation_season"` // OperationSeason, SeasonStart and SeasonEnd are populated
+	HasOperationMonths bool `json:"has_operation_months"` // Seasons is populated
+	Live               bool `json:"live"`                 // true if queried live on every request, false if served from a cached dataset
+}
+
+// Datasource is the metadata for a FarmersMarketApi implementation in the registry.
+// Every implementation registers itself through RegisterDatasource so that the
+// service (and its clients) can discover which datasources exist without the list
+// being hardcoded anywhere else.
+type Datasource struct {
+	Name         string                 `json:"name"`        // value of the datasource query parameter, e.g. "usda"
+	Title        string                 `json:"title"`       // human-readable name
+	Coverage     string                 `json:"coverage"`    // description of the area the datasource covers
+	Attribution  string                 `json:"attribution"` // attribution and license text for the underlying data
+	Capabilities DatasourceCapabilities `json:"capabilities"`
+	Area         *CoverageArea          `json:"area,omitempty"` // the area the datasource covers, nil if it covers everywhere
+
+	// Composite is true if the datasource queries the other datasources rather than an
+	// upstream of its own. They are constructed after the others, see Options.Apis, and
+	// the capabilities of their FarmersMarketApis replace Capabilities, see CapabilitiesOf.
+	Composite bool `json:"-"`
+
+	// New constructs the FarmersMarketApi backing this datasource with the upstream clients in options
+	New func(options Options) (FarmersMarketApi, error) `json:"-"`
+}
+
+// capabilitiesReporter is implemented by the FarmersMarketApis whose capabilities depend
+// on how they were constructed, e.g. on the datasources they combine
+type capabilitiesReporter interface {
+	Capabilities() DatasourceCapabilities
+}
+
+// CapabilitiesOf returns the capabilities of fmApi, constructed for datasource
+func (datasource Datasource) CapabilitiesOf(fmApi FarmersMarketApi) DatasourceCapabilities {
+	if reporter, ok := fmApi.(capabilitiesReporter); ok {
+		return reporter.Capabilities()
+	}
+
+	return datasource.Capabilities
+}
+
+var registry = struct {
+	sync.RWMutex
+	datasources map[string]Datasource
+}{datasources: make(map[string]Datasource)}
+
+// RegisterDatasource adds a datasource to the registry. It is meant to be
+// called from the init function of the file implementing the datasource and
+// panics if the datasource is malformed or the name is already taken.
+func RegisterDatasource(datasource Datasource) {
+	if datasource.Name == "" {
+		panic("api: datasource registered without a name")
+	}
+
+	if datasource.New == nil {
+		panic(fmt.Sprintf("api: datasource %s registered without a constructor", datasource.Name))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, exists := registry.datasources[datasource.Name]; exists {
+		panic(fmt.Sprintf("api: datasource %s registered twice", datasource.Name))
+	}
+
+	registry.datasources[datasource.Name] = datasource
+}
+
+// Datasources returns all registered datasources sorted by name
+func Datasources() []Datasource {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	datasources := make([]Datasource, 0, len(registry.datasources))
+	for _, datasource := range registry.datasources {
+		datasources = append(datasources, datasource)
+	}
+
+	slices.SortFunc(datasources, func(a, b Datasource) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return datasources
+}
+
+// LookupDatasource returns the registered datasource with the given name
+func LookupDatasource(name string) (Datasource, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	datasource, ok := registry.datasources[name]
+	return datasource, ok
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatasourcesAreRegistered(t *testing.T) {
+	datasources := Datasources()
+
+	var names []string
+	for _, datasource := range datasources {
+		names = append(names, datasource.Name)
+		require.NotEmpty(t, datasource.Title)
+		require.NotEmpty(t, datasource.Attribution)
+	}
+
+	require.Contains(t, names, "usda")
+	require.Contains(t, names, "newyork")
+	require.Contains(t, names, MergedDatasourceName)
+
+	_, ok := LookupDatasource(DefaultDatasourceName)
+	require.True(t, ok)
+}
+
+func TestRegisterDatasourceTwicePanics(t *testing.T) {
+	require.Panics(t, func() {
+		RegisterDatasource(Datasource{
+			Name: "usda",
+			New:  func(Options) (FarmersMarketApi, error) { return nil, nil },
+		})
+	})
+}
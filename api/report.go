@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// QueryReport collects what the client should be told about how a query was
+// answered, such as the datasource the auto datasource chose or finding fewer
+// markets than requested, and how old the data is. Datasources fill in the report
+// of the context they are queried with, if it has one.
+type QueryReport struct {
+	mu         sync.Mutex
+	datasource string
+	warnings   []string
+	dataAsOf   time.Time
+}
+
+type queryReportKey struct{}
+
+// WithQueryReport returns a copy of ctx carrying a new, empty QueryReport
+func WithQueryReport(ctx context.Context) (context.Context, *QueryReport) {
+	report := &QueryReport{}
+	return context.WithValue(ctx, queryReportKey{}, report), report
+}
+
+// QueryReportFrom returns the QueryReport carried by ctx, or nil if there is none
+func QueryReportFrom(ctx context.Context) *QueryReport {
+	report, _ := ctx.Value(queryReportKey{}).(*QueryReport)
+	return report
+}
+
+// Datasource returns the name of the datasource that answered the query, or an
+// empty string if it is the one queried
+func (report *QueryReport) Datasource() string {
+	if report == nil {
+		return ""
+	}
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	return report.datasource
+}
+
+// reportDatasource records in the report of ctx that the query was answered by datasource
+func reportDatasource(ctx context.Context, datasource string) {
+	report := QueryReportFrom(ctx)
+	if report == nil {
+		return
+	}
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	report.datasource = datasource
+}
+
+// Warnings returns what the client should know about the answer, such as finding
+// fewer markets than requested, in the order they were reported
+func (report *QueryReport) Warnings() []string {
+	if report == nil {
+		return nil
+	}
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	return slices.Clone(report.warnings)
+}
+
+// reportWarning adds a warning to the report of ctx
+func reportWarning(ctx context.Context, format string, args ...any) {
+	report := QueryReportFrom(ctx)
+	if report == nil {
+		return
+	}
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	report.warnings = append(report.warnings, fmt.Sprintf(format, args...))
+}
+
+// DataAsOf returns when the oldest of the data the query was answered with was
+// downloaded from upstream, or the zero time if it is not known
+func (report *QueryReport) DataAsOf() time.Time {
+	if report == nil {
+		return time.Time{}
+	}
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	return report.dataAsOf
+}
+
+// reportDataAsOf records in the report of ctx that data downloaded at asOf was used
+// to answer the query
+func reportDataAsOf(ctx context.Context, asOf time.Time) {
+	report := QueryReportFrom(ctx)
+	if report == nil {
+		return
+	}
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	if report.dataAsOf.IsZero() || asOf.Before(report.dataAsOf) {
+		report.dataAsOf = asOf
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryReport(t *testing.T) {
+	// Reporting to a context without a report does nothing
+	reportWarning(context.Background(), "ignored")
+	require.Nil(t, QueryReportFrom(context.Background()))
+	require.Empty(t, QueryReportFrom(context.Background()).Warnings())
+
+	ctx, report := WithQueryReport(context.Background())
+	require.Equal(t, report, QueryReportFrom(ctx))
+	require.True(t, report.DataAsOf().IsZero())
+
+	reportDatasource(ctx, "usda")
+	reportWarning(ctx, "found %d of the %d markets requested", 1, 3)
+
+	// The oldest data is reported
+	older := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	reportDataAsOf(ctx, older.Add(time.Hour))
+	reportDataAsOf(ctx, older)
+	reportDataAsOf(ctx, older.Add(2*time.Hour))
+
+	require.Equal(t, "usda", report.Datasource())
+	require.Equal(t, []string{"found 1 of the 3 markets requested"}, report.Warnings())
+	require.Equal(t, older, report.DataAsOf())
+}
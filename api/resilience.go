@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrUpstreamUnavailable is returned without asking an upstream API when its circuit
+// breaker is open, or when its rate limit would not let a request through before the
+// deadline of the context
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
+// UpstreamPolicy configures how an UpstreamGuard retries, rate limits and stops asking
+// an upstream API. The zero UpstreamPolicy makes a single attempt at every request.
+type UpstreamPolicy struct {
+	MaxAttempts    int           // attempts at a request, including the first one
+	AttemptTimeout time.Duration // bounds each attempt, so a slow one leaves time to retry; not bounded if zero
+	InitialBackoff time.Duration // wait before the first retry, doubled before every retry after it
+	MaxBackoff     time.Duration // longest wait before a retry
+	Jitter         float64       // fraction of the wait randomly added or removed, e.g. 0.5
+
+	RequestsPerSecond float64 // sustained rate of requests, not limited if zero
+	Burst             int     // requests that can be made at once after a quiet period
+
+	FailureThreshold int           // consecutive failures opening the circuit breaker, never opens if zero
+	OpenDuration     time.Duration // how long the circuit breaker stays open before a trial request
+}
+
+// backoff returns the wait before the given retry, counting from 1. random is in
+// [0, 1) and spreads the wait by the jitter.
+func (policy UpstreamPolicy) backoff(retry int, random float64) time.Duration {
+	delay := policy.InitialBackoff
+	for i := 1; i < retry && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if policy.MaxBackoff > 0 {
+		delay = min(delay, policy.MaxBackoff)
+	}
+
+	// random is in [0, 1), spread it over [-jitter, jitter)
+	jitter := (2*random - 1) * policy.Jitter
+
+	return time.Duration(float64(delay) * (1 + jitter))
+}
+
+// The policies of the real upstream APIs, for the guards of their clients. Neither the USDA nor the Socrata portals such
+// as Open Data NY publish a quota, so they are kept to a few requests per second. Free
+// GeoNames accounts are allowed 1000 requests an hour. Socrata datasets are downloaded
+// whole, so their attempts are only bounded by the deadline of the download.
+var (
+	USDAUpstreamPolicy = UpstreamPolicy{
+		MaxAttempts:       3,
+		AttemptTimeout:    4 * time.Second,
+		InitialBackoff:    200 * time.Millisecond,
+		MaxBackoff:        2 * time.Second,
+		Jitter:            0.5,
+		RequestsPerSecond: 5,
+		Burst:             10,
+		FailureThreshold:  5,
+		OpenDuration:      30 * time.Second,
+	}
+
+	SocrataUpstreamPolicy = UpstreamPolicy{
+		MaxAttempts:       3,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        10 * time.Second,
+		Jitter:            0.5,
+		RequestsPerSecond: 1,
+		Burst:             5,
+		FailureThreshold:  3,
+		OpenDuration:      time.Minute,
+	}
+
+	GeoNamesUpstreamPolicy = UpstreamPolicy{
+		MaxAttempts:       2,
+		AttemptTimeout:    3 * time.Second,
+		InitialBackoff:    200 * time.Millisecond,
+		MaxBackoff:        time.Second,
+		Jitter:            0.5,
+		RequestsPerSecond: 1000.0 / 3600,
+		Burst:             20,
+		FailureThreshold:  5,
+		OpenDuration:      time.Minute,
+	}
+)
+
+// The guards of the clients of NewDefaultOptions and of the package level functions,
+// which make a new client on every call, so that they share the rate limits and
+// circuit breakers of each upstream API
+var (
+	sharedUSDAGuard     = NewUpstreamGuard("usda", USDAUpstreamPolicy)
+	sharedNewYorkGuard  = NewUpstreamGuard("newyork", SocrataUpstreamPolicy)
+	sharedGeoNamesGuard = NewUpstreamGuard("geonames", GeoNamesUpstreamPolicy)
+)
+
+// UpstreamGuard protects an upstream API, and the requests made to it, from each
+// other. Requests that fail with a 5xx response, a timeout or a network error are
+// retried with exponential backoff and jitter, requests are rate limited with a token
+// bucket, and a circuit breaker fails requests fast while the upstream API keeps
+// failing. It is shared by every client of the same upstream API.
+type UpstreamGuard struct {
+	name    string
+	policy  UpstreamPolicy
+	limiter *tokenBucket    // nil if not rate limited
+	breaker *circuitBreaker // nil if there is no circuit breaker
+
+	// random returns a number in [0, 1) to spread the backoff, replaced in tests
+	random func() float64
+}
+
+// NewUpstreamGuard returns an UpstreamGuard for the upstream API with the given name,
+// which is only used in errors
+func NewUpstreamGuard(name string, policy UpstreamPolicy) *UpstreamGuard {
+	guard := &UpstreamGuard{name: name, policy: policy, random: rand.Float64}
+
+	if policy.RequestsPerSecond > 0 {
+		guard.limiter = newTokenBucket(policy.RequestsPerSecond, max(policy.Burst, 1))
+	}
+
+	if policy.FailureThreshold > 0 {
+		guard.breaker = newCircuitBreaker(policy.FailureThreshold, policy.OpenDuration)
+	}
+
+	return guard
+}
+
+// upstreamFailed returns true if err, returned by an attempt at a request made on
+// behalf of ctx, means the upstream API is failing rather than the request being
+// wrong or given up on: a 5xx response, a timeout or a network error
+func upstreamFailed(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var status statusError
+	if errors.As(err, &status) {
+		return status.code >= 500
+	}
+
+	// Transport errors, and the deadline of an attempt, are all net.Errors
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// do makes attempts at a request until one succeeds, fails in a way retrying would
+// not help with, or the policy gives up. Attempts are only made while the circuit
+// breaker lets them through, and as fast as the rate limit allows.
+func (guard *UpstreamGuard) do(ctx context.Context, attempt func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	for attempts := 1; ; attempts++ {
+		if !guard.breaker.allow() {
+			return nil, fmt.Errorf("%w: %s is failing, not retrying before %s", ErrUpstreamUnavailable, guard.name, guard.breaker.retryAt().Format(time.RFC3339))
+		}
+
+		if err := guard.limiter.wait(ctx); err != nil {
+			guard.breaker.abandon()
+			return nil, fmt.Errorf("%s: %w", guard.name, err)
+		}
+
+		body, err := guard.attempt(ctx, attempt)
+
+		// Any other answer, even an error, shows the upstream API is up
+		failed := upstreamFailed(ctx, err)
+		switch {
+		case failed:
+			guard.breaker.failure()
+		case ctx.Err() != nil:
+			guard.breaker.abandon()
+		default:
+			guard.breaker.success()
+		}
+
+		if !failed || attempts >= guard.policy.MaxAttempts {
+			if failed && attempts > 1 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+			}
+
+			return body, err
+		}
+
+		delay := guard.policy.backoff(attempts, guard.random())
+
+		// Waiting would only end in the deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return nil, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single attempt, bounded by the AttemptTimeout of the policy
+func (guard *UpstreamGuard) attempt(ctx context.Context, attempt func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if guard.policy.AttemptTimeout <= 0 {
+		return attempt(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, guard.policy.AttemptTimeout)
+	defer cancel()
+
+	return attempt(attemptCtx)
+}
+
+// tokenBucket is a rate limiter holding up to burst tokens, which are added at rate
+// per second. Every request takes a token, and waits for it if there is none left.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64 // negative when requests are waiting for tokens
+	last   time.Time
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// newTokenBucket returns a full tokenBucket
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+// reserve takes a token and returns how long to wait before it is there
+func (bucket *tokenBucket) reserve() time.Duration {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	now := bucket.now()
+	if !bucket.last.IsZero() {
+		bucket.tokens = min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	}
+	bucket.last = now
+
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
+
+// cancel gives back a reserved token that was not used
+func (bucket *tokenBucket) cancel() {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	bucket.tokens = min(bucket.burst, bucket.tokens+1)
+}
+
+// wait takes a token, waiting for it if needed. It returns an error wrapping
+// ErrUpstreamUnavailable right away if the token would come after the deadline of
+// ctx. A nil tokenBucket does not limit anything.
+func (bucket *tokenBucket) wait(ctx context.Context) error {
+	if bucket == nil {
+		return nil
+	}
+
+	delay := bucket.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		bucket.cancel()
+		return fmt.Errorf("%w: rate limit reached", ErrUpstreamUnavailable)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		bucket.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// circuitBreaker stops requests to an upstream API after threshold consecutive
+// failures. Once it has been open for openDuration, it lets a single trial request
+// through, which closes it if it succeeds and opens it again otherwise.
+type circuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+
+	mu       sync.Mutex
+	failures int       // consecutive failures
+	openedAt time.Time // zero while closed
+	trial    bool      // a trial request is in flight
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// newCircuitBreaker returns a closed circuitBreaker
+func newCircuitBreaker(threshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openDuration: openDuration, now: time.Now}
+}
+
+// allow returns true if a request may be made. Every allowed request must be followed
+// by a call to success, failure or abandon. A nil circuitBreaker allows everything.
+func (breaker *circuitBreaker) allow() bool {
+	if breaker == nil {
+		return true
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if breaker.openedAt.IsZero() {
+		return true
+	}
+
+	if breaker.trial || breaker.now().Before(breaker.openedAt.Add(breaker.openDuration)) {
+		return false
+	}
+
+	breaker.trial = true
+
+	return true
+}
+
+// retryAt returns when the open circuitBreaker lets a trial request through
+func (breaker *circuitBreaker) retryAt() time.Time {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	return breaker.openedAt.Add(breaker.openDuration)
+}
+
+// success records an allowed request that succeeded, which closes the circuitBreaker
+func (breaker *circuitBreaker) success() {
+	if breaker == nil {
+		return
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures = 0
+	breaker.openedAt = time.Time{}
+	breaker.trial = false
+}
+
+// failure records an allowed request that failed, which opens the circuitBreaker
+// if it was a trial or one failure too many
+func (breaker *circuitBreaker) failure() {
+	if breaker == nil {
+		return
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures++
+	if breaker.trial || breaker.failures >= breaker.threshold {
+		breaker.openedAt = breaker.now()
+	}
+	breaker.trial = false
+}
+
+// abandon records an allowed request that neither succeeded nor failed, such as one
+// given up on by the caller
+func (breaker *circuitBreaker) abandon() {
+	if breaker == nil {
+		return
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.trial = false
+}
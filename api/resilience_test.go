@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGuardedClient returns a client guarded by policy in front of handler, and
+// the number of requests handler received
+func newTestGuardedClient(t *testing.T, policy UpstreamPolicy, handler func(w http.ResponseWriter, r *http.Request, request int)) (UpstreamClient, *UpstreamGuard, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, int(requests.Add(1)))
+	}))
+	t.Cleanup(server.Close)
+
+	guard := NewUpstreamGuard("test", policy)
+	guard.random = func() float64 { return 0.5 }
+
+	return UpstreamClient{BaseURL: server.URL, Guard: guard}, guard, &requests
+}
+
+func TestUpstreamPolicyBackoff(t *testing.T) {
+	policy := UpstreamPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+
+	// random = 0.5 means no jitter
+	require.Equal(t, 100*time.Millisecond, policy.backoff(1, 0.5))
+	require.Equal(t, 200*time.Millisecond, policy.backoff(2, 0.5))
+	require.Equal(t, 800*time.Millisecond, policy.backoff(4, 0.5))
+	require.Equal(t, time.Second, policy.backoff(5, 0.5))
+	require.Equal(t, time.Second, policy.backoff(1000, 0.5))
+
+	require.Equal(t, 50*time.Millisecond, policy.backoff(1, 0))
+	require.InDelta(t, float64(150*time.Millisecond), float64(policy.backoff(1, 0.999999)), float64(time.Millisecond))
+}
+
+func TestUpstreamGuardRetries(t *testing.T) {
+	policy := UpstreamPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	// Two 5xx responses are retried
+	client, _, requests := newTestGuardedClient(t, policy, func(w http.ResponseWriter, _ *http.Request, request int) {
+		if request < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.Write([]byte(`[]`))
+	})
+
+	body, err := client.get(context.Background(), nil, "")
+	require.Nil(t, err)
+	require.Equal(t, `[]`, string(body))
+	require.Equal(t, int32(3), requests.Load())
+
+	// Three are one too many
+	client, _, requests = newTestGuardedClient(t, policy, func(w http.ResponseWriter, _ *http.Request, _ int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err = client.get(context.Background(), nil, "")
+	require.ErrorContains(t, err, "giving up after 3 attempts")
+	require.Equal(t, int32(3), requests.Load())
+
+	// Other errors are not
+	client, _, requests = newTestGuardedClient(t, policy, func(w http.ResponseWriter, _ *http.Request, _ int) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	_, err = client.get(context.Background(), nil, "")
+	require.NotNil(t, err)
+	require.Equal(t, int32(1), requests.Load())
+}
+
+func TestUpstreamGuardRetriesTimeouts(t *testing.T) {
+	policy := UpstreamPolicy{MaxAttempts: 2, AttemptTimeout: 50 * time.Millisecond, InitialBackoff: time.Millisecond}
+
+	client, _, requests := newTestGuardedClient(t, policy, func(w http.ResponseWriter, r *http.Request, request int) {
+		if request == 1 {
+			<-r.Context().Done()
+			return
+		}
+
+		w.Write([]byte(`[]`))
+	})
+
+	body, err := client.get(context.Background(), nil, "")
+	require.Nil(t, err)
+	require.Equal(t, `[]`, string(body))
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestUpstreamGuardCircuitBreaker(t *testing.T) {
+	policy := UpstreamPolicy{MaxAttempts: 1, FailureThreshold: 2, OpenDuration: time.Minute}
+
+	var failing atomic.Bool
+	failing.Store(true)
+
+	client, guard, requests := newTestGuardedClient(t, policy, func(w http.ResponseWriter, _ *http.Request, _ int) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(`[]`))
+	})
+
+	now := time.Date(2024, time.May, 4, 12, 0, 0, 0, time.UTC)
+	guard.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := client.get(context.Background(), nil, "")
+		require.NotNil(t, err)
+		require.NotErrorIs(t, err, ErrUpstreamUnavailable)
+	}
+
+	// The breaker is open, the upstream API is not asked
+	_, err := client.get(context.Background(), nil, "")
+	require.ErrorIs(t, err, ErrUpstreamUnavailable)
+	require.Equal(t, int32(2), requests.Load())
+
+	// A failed trial opens it again
+	now = now.Add(2 * time.Minute)
+
+	_, err = client.get(context.Background(), nil, "")
+	require.NotErrorIs(t, err, ErrUpstreamUnavailable)
+	require.Equal(t, int32(3), requests.Load())
+
+	_, err = client.get(context.Background(), nil, "")
+	require.ErrorIs(t, err, ErrUpstreamUnavailable)
+
+	// A successful trial closes it
+	now = now.Add(2 * time.Minute)
+	failing.Store(false)
+
+	for i := 0; i < 2; i++ {
+		_, err = client.get(context.Background(), nil, "")
+		require.Nil(t, err)
+	}
+
+	require.Equal(t, int32(5), requests.Load())
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2024, time.May, 4, 12, 0, 0, 0, time.UTC)
+
+	bucket := newTokenBucket(2, 2)
+	bucket.now = func() time.Time { return now }
+
+	// The burst is free, then tokens come every half second
+	require.Equal(t, time.Duration(0), bucket.reserve())
+	require.Equal(t, time.Duration(0), bucket.reserve())
+	require.Equal(t, 500*time.Millisecond, bucket.reserve())
+	require.Equal(t, time.Second, bucket.reserve())
+
+	// The reserved tokens are used up first
+	now = now.Add(time.Second)
+	require.Equal(t, 500*time.Millisecond, bucket.reserve())
+
+	// A token that would come after the deadline is not waited for
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, bucket.wait(ctx), ErrUpstreamUnavailable)
+
+	// Nor taken
+	now = now.Add(time.Second)
+	require.Equal(t, time.Duration(0), bucket.reserve())
+}
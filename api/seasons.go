@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Season is a category of months a market operates in. The categories are the ones
+// of the New York State Operation Months Code, whose meanings we extracted from the
+// documentation of the dataset by Socrata [1]. Categories are NOT mutually exclusive.
+//
+// [1]: https://dev.socrata.com/foundry/data.ny.gov/xjya-f8ng
+type Season uint8
+
+const (
+	Spring         Season = 1 << iota // operating at some point in April or May
+	Summer                            // operating at some point from June to November
+	ExtendedSeason                    // operating at some point in December
+	Winter                            // operating at some point from January to March
+	YearRound                         // continually operating at the same location
+)
+
+// allSeasons lists every Season in the order they are displayed
+var allSeasons = []Season{Spring, Summer, ExtendedSeason, Winter, YearRound}
+
+var seasonCodes = map[Season]string{
+	Spring:         "P",
+	Summer:         "M",
+	ExtendedSeason: "X",
+	Winter:         "W",
+	YearRound:      "YR",
+}
+
+var seasonNames = map[Season]string{
+	Spring:         "spring",
+	Summer:         "summer",
+	ExtendedSeason: "extended",
+	Winter:         "winter",
+	YearRound:      "year-round",
+}
+
+var seasonTitles = map[Season]string{
+	Spring:         "Spring",
+	Summer:         "Summer",
+	ExtendedSeason: "Extended Season",
+	Winter:         "Winter",
+	YearRound:      "Year-Round",
+}
+
+// String returns the name of the season as it appears in JSON, e.g. "extended"
+func (season Season) String() string {
+	return seasonNames[season]
+}
+
+// Code returns the Operation Months Code of the season, e.g. "X"
+func (season Season) Code() string {
+	return seasonCodes[season]
+}
+
+// Title returns the human-readable name of the season, e.g. "Extended Season"
+func (season Season) Title() string {
+	return seasonTitles[season]
+}
+
+// ParseSeason returns the Season for its Operation Months Code (e.g. "W") or its
+// name (e.g. "winter")
+func ParseSeason(text string) (Season, error) {
+	for _, season := range allSeasons {
+		if strings.EqualFold(text, season.Code()) || strings.EqualFold(text, season.String()) {
+			return season, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown season: %q", text)
+}
+
+// SeasonSet is a set of the Seasons a market operates in. Seasons are not mutually
+// exclusive. It is marshalled as a list of season names, e.g. ["spring", "summer"].
+type SeasonSet uint8
+
+// NewSeasonSet returns the set of the given seasons
+func NewSeasonSet(seasons ...Season) SeasonSet {
+	var set SeasonSet
+	for _, season := range seasons {
+		set |= SeasonSet(season)
+	}
+
+	return set
+}
+
+// Has returns true if season is in the set
+func (set SeasonSet) Has(season Season) bool {
+	return set&SeasonSet(season) != 0
+}
+
+// OperatesIn returns true if a market operating in these seasons operates in season,
+// which is always the case for year-round markets
+func (set SeasonSet) OperatesIn(season Season) bool {
+	return set.Has(season) || set.Has(YearRound)
+}
+
+// Seasons returns the seasons in the set
+func (set SeasonSet) Seasons() []Season {
+	var seasons []Season
+	for _, season := range allSeasons {
+		if set.Has(season) {
+			seasons = append(seasons, season)
+		}
+	}
+
+	return seasons
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (set SeasonSet) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for _, season := range set.Seasons() {
+		names = append(names, season.String())
+	}
+
+	return json.Marshal(names)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (set *SeasonSet) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+
+	*set = 0
+	for _, name := range names {
+		season, err := ParseSeason(name)
+		if err != nil {
+			return err
+		}
+
+		*set |= SeasonSet(season)
+	}
+
+	return nil
+}
+
+// ParseOperationMonthsCode decodes an Operation Months Code such as "M", "YR" or a
+// combination such as "P,M,X" or "PMX"
+func ParseOperationMonthsCode(code string) (SeasonSet, error) {
+	// Separators between codes are optional
+	remaining := strings.ToUpper(strings.NewReplacer(",", "", " ", "", "/", "", "&", "", ";", "").Replace(code))
+
+	var set SeasonSet
+
+	for remaining != "" {
+		found := false
+
+		// YR is the only code longer than a letter, so check it first
+		for _, season := range []Season{YearRound, Spring, Summer, ExtendedSeason, Winter} {
+			if strings.HasPrefix(remaining, season.Code()) {
+				set |= SeasonSet(season)
+				remaining = remaining[len(season.Code()):]
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("unknown operation months code: %q", code)
+		}
+	}
+
+	return set, nil
+}
+
+// seasonOfMonth is the Season each month belongs to
+var seasonOfMonth = map[time.Month]Season{
+	time.January:   Winter,
+	time.February:  Winter,
+	time.March:     Winter,
+	time.April:     Spring,
+	time.May:       Spring,
+	time.June:      Summer,
+	time.July:      Summer,
+	time.August:    Summer,
+	time.September: Summer,
+	time.October:   Summer,
+	time.November:  Summer,
+	time.December:  ExtendedSeason,
+}
+
+// SeasonsBetween returns the seasons of a market operating from start to end, which
+// wraps around the end of the year if end is before start. It lets datasources without
+// an Operation Months Code derive one from their season dates.
+func SeasonsBetween(start MonthDay, end MonthDay) SeasonSet {
+	if start == (MonthDay{Month: time.January, Day: 1}) && end == (MonthDay{Month: time.December, Day: 31}) {
+		return NewSeasonSet(YearRound)
+	}
+
+	var set SeasonSet
+
+	month := start.Month
+	for i := 0; i < 12; i++ {
+		set |= SeasonSet(seasonOfMonth[month])
+
+		// A season that starts and ends in the same month may still wrap around the year
+		if month == end.Month && (i > 0 || start.ordinal() <= end.ordinal()) {
+			break
+		}
+
+		month = month%12 + 1
+	}
+
+	return set
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOperationMonthsCode(t *testing.T) {
+	for code, expected := range map[string]SeasonSet{
+		"":        0,
+		"M":       NewSeasonSet(Summer),
+		"YR":      NewSeasonSet(YearRound),
+		"P,M,X":   NewSeasonSet(Spring, Summer, ExtendedSeason),
+		"PMX":     NewSeasonSet(Spring, Summer, ExtendedSeason),
+		"w, yr":   NewSeasonSet(Winter, YearRound),
+		"M/X & W": NewSeasonSet(Summer, ExtendedSeason, Winter),
+	} {
+		set, err := ParseOperationMonthsCode(code)
+		require.Nil(t, err, code)
+		require.Equal(t, expected, set, code)
+	}
+
+	for _, code := range []string{"Q", "Y", "M,Summer"} {
+		_, err := ParseOperationMonthsCode(code)
+		require.NotNil(t, err, code)
+	}
+}
+
+func TestParseSeason(t *testing.T) {
+	for text, expected := range map[string]Season{"P": Spring, "yr": YearRound, "Extended": ExtendedSeason, "year-round": YearRound} {
+		season, err := ParseSeason(text)
+		require.Nil(t, err, text)
+		require.Equal(t, expected, season, text)
+	}
+
+	_, err := ParseSeason("autumn")
+	require.NotNil(t, err)
+}
+
+func TestSeasonSetJSON(t *testing.T) {
+	set := NewSeasonSet(ExtendedSeason, Spring)
+
+	data, err := json.Marshal(set)
+	require.Nil(t, err)
+	require.Equal(t, `["spring","extended"]`, string(data))
+
+	var decoded SeasonSet
+	require.Nil(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, set, decoded)
+
+	data, err = json.Marshal(SeasonSet(0))
+	require.Nil(t, err)
+	require.Equal(t, `[]`, string(data))
+
+	require.NotNil(t, json.Unmarshal([]byte(`["autumn"]`), &decoded))
+}
+
+func TestSeasonsBetween(t *testing.T) {
+	for _, test := range []struct {
+		start, end MonthDay
+		expected   SeasonSet
+	}{
+		{MonthDay{time.January, 1}, MonthDay{time.December, 31}, NewSeasonSet(YearRound)},
+		{MonthDay{time.May, 4}, MonthDay{time.November, 23}, NewSeasonSet(Spring, Summer)},
+		{MonthDay{time.June, 1}, MonthDay{time.June, 30}, NewSeasonSet(Summer)},
+		{MonthDay{time.November, 1}, MonthDay{time.March, 31}, NewSeasonSet(Summer, ExtendedSeason, Winter)},
+		{MonthDay{time.June, 15}, MonthDay{time.June, 1}, NewSeasonSet(Spring, Summer, ExtendedSeason, Winter)},
+	} {
+		require.Equal(t, test.expected, SeasonsBetween(test.start, test.end), "%v to %v", test.start, test.end)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"strings"
+	"unicode"
+)
+
+// nameStopWords are left out when comparing market names, since nearly every
+// market is called a farmers' market of some kind
+var nameStopWords = map[string]bool{
+	"the": true, "farmers": true, "farmer": true, "farm": true, "market": true,
+	"markets": true, "mkt": true, "greenmarket": true, "at": true, "of": true, "and": true,
+}
+
+// addressAbbreviations are the usual abbreviations of words in street addresses
+var addressAbbreviations = map[string]string{
+	"street": "st", "avenue": "ave", "av": "ave", "road": "rd", "boulevard": "blvd",
+	"drive": "dr", "lane": "ln", "place": "pl", "court": "ct", "square": "sq",
+	"parkway": "pkwy", "highway": "hwy", "route": "rte", "plaza": "plz", "terrace": "ter",
+	"north": "n", "south": "s", "east": "e", "west": "w",
+}
+
+// normalizeWords lowercases text, drops its punctuation and the words in stopWords,
+// and replaces the words in abbreviations
+func normalizeWords(text string, stopWords map[string]bool, abbreviations map[string]string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		// "Farmers'" and "Farmer's" are the same word
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		word := strings.NewReplacer("'", "", "’", "").Replace(field)
+
+		if abbreviation, ok := abbreviations[word]; ok {
+			word = abbreviation
+		}
+
+		if word != "" && !stopWords[word] {
+			words = append(words, word)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// normalizeMarketName returns the words of a market name that tell it apart from others
+func normalizeMarketName(name string) string {
+	return normalizeWords(name, nameStopWords, nil)
+}
+
+// normalizeStreet returns a street address with its words abbreviated the usual way
+func normalizeStreet(street string) string {
+	return normalizeWords(street, nil, addressAbbreviations)
+}
+
+// bigrams returns how often every pair of adjacent characters occurs in text
+func bigrams(text string) map[string]int {
+	runes := []rune(text)
+
+	counts := make(map[string]int)
+	for idx := 0; idx+1 < len(runes); idx++ {
+		counts[string(runes[idx:idx+2])]++
+	}
+
+	return counts
+}
+
+// similarity returns the Sørensen–Dice coefficient of the character bigrams of a and b,
+// from 0 for nothing in common to 1 for the same text. It tolerates typos, missing
+// words and words in a different order. Text that is missing is like nothing else,
+// not even other missing text.
+func similarity(a string, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+
+	if a == b {
+		return 1
+	}
+
+	aBigrams, bBigrams := bigrams(a), bigrams(b)
+
+	total := 0
+	for _, count := range aBigrams {
+		total += count
+	}
+	for _, count := range bBigrams {
+		total += count
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	common := 0
+	for bigram, count := range aBigrams {
+		common += min(count, bBigrams[bigram])
+	}
+
+	return 2 * float64(common) / float64(total)
+}
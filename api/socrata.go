@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// socrataPageSize is the number of rows asked for at once, the most a SODA endpoint
+// returns when no $limit is given
+const socrataPageSize = 1000
+
+// socrataMaxRows bounds the rows downloaded from a dataset, so that a portal ignoring
+// $offset cannot keep us paging forever
+const socrataMaxRows = 100000
+
+// SocrataDatasetEndpoint returns the SODA endpoint of the dataset with the given ID on
+// the Socrata portal of domain, e.g. data.ny.gov
+func SocrataDatasetEndpoint(domain string, datasetID string) string {
+	return "https://" + domain + "/resource/" + datasetID + ".json"
+}
+
+// SocrataClient is a client for a dataset published on a Socrata open data portal,
+// through the Socrata Open Data API (SODA) [1]. Many states publish their farmers'
+// markets on one.
+//
+// [1]: https://dev.socrata.com/docs/endpoints.html
+type SocrataClient struct {
+	UpstreamClient
+}
+
+// NewSocrataClient returns a client for the dataset with the given ID on the Socrata
+// portal of domain. The app token is optional, requests made without one share a lower
+// rate limit with everyone else. The guard should be shared by every client of the
+// portal, see SocrataUpstreamPolicy, a single attempt is made at every request if it
+// is nil.
+func NewSocrataClient(domain string, datasetID string, appToken string, guard *UpstreamGuard) *SocrataClient {
+	client := &SocrataClient{
+		UpstreamClient: UpstreamClient{
+			HTTPClient: defaultHTTPClient,
+			BaseURL:    SocrataDatasetEndpoint(domain, datasetID),
+			UserAgent:  defaultUserAgent,
+			Guard:      guard,
+		},
+	}
+
+	if appToken != "" {
+		client.Header = http.Header{"X-App-Token": {appToken}}
+	}
+
+	return client
+}
+
+// FetchRows returns every row of the dataset, keyed by column, see ParseFileDataset.
+// The rows are asked for a page at a time, in the order of their row IDs so that the
+// pages neither overlap nor leave rows out.
+func (client *SocrataClient) FetchRows(ctx context.Context) ([]map[string]string, error) {
+	var rows []map[string]string
+
+	for offset := 0; ; offset += socrataPageSize {
+		if offset >= socrataMaxRows {
+			return nil, fmt.Errorf("dataset has more than %d rows", socrataMaxRows)
+		}
+
+		query := url.Values{
+			"$limit":  {strconv.Itoa(socrataPageSize)},
+			"$offset": {strconv.Itoa(offset)},
+			"$order":  {":id"},
+		}
+
+		// We expect a json file
+		body, err := client.get(ctx, query, "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch rows from %d: %w", offset, err)
+		}
+
+		page, err := ParseFileDataset(body, FileFormatJSON)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse rows from %d: %w", offset, err)
+		}
+
+		rows = append(rows, page...)
+
+		if len(page) < socrataPageSize {
+			return rows, nil
+		}
+	}
+}
+
+// SocrataDatasourceOptions describes a datasource downloaded from a dataset on a
+// Socrata portal. Adding the farmers' markets of a state that publishes them on one
+// only takes a new SocrataDatasourceOptions.
+type SocrataDatasourceOptions struct {
+	Name        string        `json:"name"`                  // value of the datasource query parameter
+	Title       string        `json:"title,omitempty"`       // human-readable name, Name if empty
+	Coverage    string        `json:"coverage,omitempty"`    // description of the area the dataset covers
+	Attribution string        `json:"attribution,omitempty"` // attribution and license text for the dataset
+	Area        *CoverageArea `json:"area,omitempty"`        // the area the dataset covers, see Datasource.Area
+
+	Domain    string       `json:"domain"`              // of the portal, e.g. data.ny.gov
+	DatasetID string       `json:"dataset_id"`          // e.g. xjya-f8ng, or the alias of the dataset
+	AppToken  string       `json:"app_token,omitempty"` // see NewSocrataClient
+	Mapping   FieldMapping `json:"mapping"`
+}
+
+// withDefaults returns the options with the Title filled in, or an error if they cannot
+// describe a datasource
+func (socrataOptions SocrataDatasourceOptions) withDefaults() (SocrataDatasourceOptions, error) {
+	if socrataOptions.Name == "" || strings.Contains(socrataOptions.Name, marketIDSeparator) {
+		return socrataOptions, fmt.Errorf("invalid socrata datasource name: %q", socrataOptions.Name)
+	}
+
+	if socrataOptions.Domain == "" || socrataOptions.DatasetID == "" {
+		return socrataOptions, fmt.Errorf("socrata datasource %s needs a domain and a dataset id", socrataOptions.Name)
+	}
+
+	if socrataOptions.Title == "" {
+		socrataOptions.Title = socrataOptions.Name
+	}
+
+	if err := socrataOptions.Mapping.validate(); err != nil {
+		return socrataOptions, fmt.Errorf("socrata datasource %s: %w", socrataOptions.Name, err)
+	}
+
+	return socrataOptions, nil
+}
+
+// datasource returns the registry entry of the datasource described by the options,
+// whose FarmersMarketApis are constructed by newApi
+func (socrataOptions SocrataDatasourceOptions) datasource(newApi func(Options) (FarmersMarketApi, error)) Datasource {
+	return Datasource{
+		Name:         socrataOptions.Name,
+		Title:        socrataOptions.Title,
+		Coverage:     socrataOptions.Coverage,
+		Attribution:  socrataOptions.Attribution,
+		Capabilities: socrataOptions.Mapping.Capabilities(),
+		Area:         socrataOptions.Area,
+		New:          newApi,
+	}
+}
+
+// ReadSocrataDatasourceOptions reads a JSON array of SocrataDatasourceOptions
+func ReadSocrataDatasourceOptions(path string) ([]SocrataDatasourceOptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read socrata datasources: %w", err)
+	}
+
+	var socrataOptions []SocrataDatasourceOptions
+	if err := json.Unmarshal(data, &socrataOptions); err != nil {
+		return nil, fmt.Errorf("could not parse socrata datasources: %w", err)
+	}
+
+	return socrataOptions, nil
+}
+
+// RegisterSocrataDatasource adds a datasource downloaded from a Socrata portal to the
+// registry. Like RegisterFileDatasource, it is meant to be called with the
+// configuration of the service and returns an error instead of panicking. The
+// FarmersMarketApis constructed for the datasource share a single SocrataClient, and
+// only use the Geocoder of their Options.
+func RegisterSocrataDatasource(socrataOptions SocrataDatasourceOptions) error {
+	socrataOptions, err := socrataOptions.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := LookupDatasource(socrataOptions.Name); exists {
+		return fmt.Errorf("datasource %s already exists", socrataOptions.Name)
+	}
+
+	client := NewSocrataClient(socrataOptions.Domain, socrataOptions.DatasetID, socrataOptions.AppToken,
+		NewUpstreamGuard(socrataOptions.Name, SocrataUpstreamPolicy))
+
+	RegisterDatasource(socrataOptions.datasource(func(options Options) (FarmersMarketApi, error) {
+		return NewSocrataFarmersMarketApi(socrataOptions, client, options.Geocoder)
+	}))
+
+	return nil
+}
+
+// SocrataFarmersMarketApi is the implementation of the FarmersMarketApi that downloads
+// the markets from a dataset on a Socrata portal. The whole dataset is downloaded on the
+// first query and served from memory afterwards.
+type SocrataFarmersMarketApi struct {
+	options      SocrataDatasourceOptions
+	capabilities DatasourceCapabilities
+	client       *SocrataClient
+	geocoder     Geocoder
+	dataset      *cachedDataset
+}
+
+// NewSocrataFarmersMarketApi returns a pointer to a freshly constructed Farmers Market Api
+// serving the dataset described by socrataOptions, downloaded with client. The
+// Domain, DatasetID and AppToken of socrataOptions are those of the client.
+func NewSocrataFarmersMarketApi(socrataOptions SocrataDatasourceOptions, client *SocrataClient, geocoder Geocoder) (*SocrataFarmersMarketApi, error) {
+	socrataOptions, err := socrataOptions.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, fmt.Errorf("%s %w", socrataOptions.Name, ErrClientNotConfigured)
+	}
+
+	if geocoder == nil {
+		return nil, fmt.Errorf("geocoder not configured")
+	}
+
+	fmApi := &SocrataFarmersMarketApi{
+		options:      socrataOptions,
+		capabilities: socrataOptions.Mapping.Capabilities(),
+		client:       client,
+		geocoder:     geocoder,
+	}
+
+	fmApi.dataset = newCachedDataset(fmApi.load)
+
+	return fmApi, nil
+}
+
+// Refresh downloads the dataset again. Queries keep being served from the
+// previous dataset until the new one has been loaded.
+func (fmApi *SocrataFarmersMarketApi) Refresh(ctx context.Context) error {
+	return fmApi.dataset.refresh(ctx)
+}
+
+// load downloads and converts the dataset
+func (fmApi *SocrataFarmersMarketApi) load(ctx context.Context) ([]FarmersMarketRecord, error) {
+	rows, err := fmApi.client.FetchRows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch %s data: %w", fmApi.options.Name, err)
+	}
+
+	records, err := fmApi.options.Mapping.Records(ctx, fmApi.options.Name, rows)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert %s data: %w", fmApi.options.Name, err)
+	}
+
+	return records, nil
+}
+
+func (fmApi *SocrataFarmersMarketApi) NearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error) {
+	if err := filter.supportedBy(fmApi.options.Name, fmApi.capabilities); err != nil {
+		return nil, err
+	}
+
+	return fmApi.dataset.nearestN(ctx, n, location, filter)
+}
+
+func (fmApi *SocrataFarmersMarketApi) NearestNByZipCode(ctx context.Context, n int, zipcode string, filter Filter) ([]FarmersMarketRecord, error) {
+	location, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return fmApi.NearestN(ctx, n, location, filter)
+}
+
+func (fmApi *SocrataFarmersMarketApi) WithinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error) {
+	return fmApi.dataset.withinRadius(ctx, location, distance)
+}
+
+func (fmApi *SocrataFarmersMarketApi) WithinRadiusByZipCode(ctx context.Context, zipcode string, distance float64) ([]FarmersMarketRecord, error) {
+	location, err := fmApi.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return fmApi.WithinRadius(ctx, location, distance)
+}
+
+func (fmApi *SocrataFarmersMarketApi) WithinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error) {
+	return fmApi.dataset.withinBounds(ctx, southWest, northEast, limit)
+}
+
+func (fmApi *SocrataFarmersMarketApi) Market(ctx context.Context, id string) (FarmersMarketRecord, error) {
+	return fmApi.dataset.market(ctx, id)
+}
+
+// Verify that SocrataFarmersMarketApi implements FarmersMarketApi
+var _ FarmersMarketApi = (*SocrataFarmersMarketApi)(nil)
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socrataTestOptions describes a dataset laid out like the New York State one, with
+// yes or no columns of its own
+var socrataTestOptions = SocrataDatasourceOptions{
+	Name:      "examplestate",
+	Domain:    "data.example.gov",
+	DatasetID: "abcd-1234",
+	Mapping: FieldMapping{
+		ID:                            "market_id",
+		Name:                          "market_name",
+		Latitude:                      "latitude",
+		Longitude:                     "longitude",
+		Website:                       "market_link.url",
+		SnapStatus:                    "snap",
+		FarmersMarketNutritionProgram: "fmnp",
+	},
+}
+
+// newTestSocrataClient returns a client for a stand-in Socrata portal serving rows
+// pages of the given JSON objects, and the number of requests the portal received
+func newTestSocrataClient(t *testing.T, rows []string) (*SocrataClient, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		query := r.URL.Query()
+		assert.Equal(t, ":id", query.Get("$order"))
+		assert.Equal(t, "token", r.Header.Get("X-App-Token"))
+
+		limit, _ := strconv.Atoi(query.Get("$limit"))
+		offset, _ := strconv.Atoi(query.Get("$offset"))
+
+		page := rows[min(offset, len(rows)):min(offset+limit, len(rows))]
+		w.Write([]byte("[" + strings.Join(page, ",") + "]"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewSocrataClient(socrataTestOptions.Domain, socrataTestOptions.DatasetID, "token", nil)
+	client.BaseURL = server.URL
+
+	return client, &requests
+}
+
+func TestSocrataClientFetchRows(t *testing.T) {
+	require.Equal(t, "https://data.example.gov/resource/abcd-1234.json", NewSocrataClient("data.example.gov", "abcd-1234", "", nil).BaseURL)
+
+	var rows []string
+	for idx := 0; idx < 2*socrataPageSize+1; idx++ {
+		rows = append(rows, fmt.Sprintf(`{"market_id": "%d"}`, idx))
+	}
+
+	client, requests := newTestSocrataClient(t, rows)
+
+	fetched, err := client.FetchRows(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 3, *requests)
+	require.Equal(t, len(rows), len(fetched))
+	require.Equal(t, "0", fetched[0]["market_id"])
+	require.Equal(t, strconv.Itoa(2*socrataPageSize), fetched[2*socrataPageSize]["market_id"])
+
+	// A page that is not full is the last one
+	client, requests = newTestSocrataClient(t, nil)
+
+	fetched, err = client.FetchRows(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 1, *requests)
+	require.Empty(t, fetched)
+}
+
+func TestSocrataDatasourceOptions(t *testing.T) {
+	options, err := socrataTestOptions.withDefaults()
+	require.Nil(t, err)
+	require.Equal(t, "examplestate", options.Title)
+
+	invalid := socrataTestOptions
+	invalid.Name = "examplestate:2024"
+	_, err = invalid.withDefaults()
+	require.NotNil(t, err)
+
+	invalid = socrataTestOptions
+	invalid.DatasetID = ""
+	_, err = invalid.withDefaults()
+	require.NotNil(t, err)
+
+	invalid = socrataTestOptions
+	invalid.Mapping = FieldMapping{Name: "market_name"}
+	_, err = invalid.withDefaults()
+	require.NotNil(t, err)
+
+	// Names are not shared with other datasources
+	taken := socrataTestOptions
+	taken.Name = "newyork"
+	require.NotNil(t, RegisterSocrataDatasource(taken))
+
+	path := filepath.Join(t.TempDir(), "socrata.json")
+	require.Nil(t, os.WriteFile(path, []byte(`[{"name": "examplestate", "domain": "data.example.gov", "dataset_id": "abcd-1234",
+		"mapping": {"name": "market_name", "latitude": "latitude", "longitude": "longitude", "fmnp": "fmnp"}}]`), 0o644))
+
+	socrataOptions, err := ReadSocrataDatasourceOptions(path)
+	require.Nil(t, err)
+	require.Equal(t, []SocrataDatasourceOptions{{
+		Name:      "examplestate",
+		Domain:    "data.example.gov",
+		DatasetID: "abcd-1234",
+		Mapping:   FieldMapping{Name: "market_name", Latitude: "latitude", Longitude: "longitude", FarmersMarketNutritionProgram: "fmnp"},
+	}}, socrataOptions)
+}
+
+func TestSocrataFarmersMarketApi(t *testing.T) {
+	client, _ := newTestSocrataClient(t, []string{
+		`{"market_id": "1", "market_name": "Pearl Street Market", "latitude": "42.64819", "longitude": "-73.75383",
+			"market_link": {"url": "https://example.com/pearl"}, "snap": "Y", "fmnp": "N"}`,
+		`{"market_id": "2", "market_name": "Troy Waterfront Market", "latitude": "42.73", "longitude": "-73.69",
+			"snap": "N", "fmnp": "Y"}`,
+	})
+
+	fmApi, err := NewSocrataFarmersMarketApi(socrataTestOptions, client, GeocoderChain{})
+	require.Nil(t, err)
+
+	records, err := fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "Pearl Street Market", records[0].Name)
+	require.Equal(t, "https://example.com/pearl", records[0].Website)
+
+	records, err = fmApi.NearestN(context.Background(), -1, albany, Filter{SnapStatus: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Pearl Street Market", records[0].Name)
+
+	records, err = fmApi.NearestN(context.Background(), -1, albany, Filter{FarmersMarketNutritionProgram: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Troy Waterfront Market", records[0].Name)
+
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{Season: Winter})
+	require.ErrorIs(t, err, ErrUnsupportedFilter)
+
+	record, err := fmApi.Market(context.Background(), "examplestate:2")
+	require.Nil(t, err)
+	require.True(t, record.FarmersMarketNutritionProgram)
+	require.False(t, record.SnapStatus)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// Embed the time zone database so that time zones can be loaded on
+	// hosts without one, such as minimal containers
+	_ "time/tzdata"
+)
+
+// stateTimeZones maps the two letter code and the name of every US state and
+// territory to the time zone most of it is in. The few states split between
+// time zones, such as Texas or Indiana, get the time zone of their largest cities.
+var stateTimeZones = map[string]string{}
+
+func init() {
+	for _, state := range []struct{ code, name, timeZone string }{
+		{"AL", "Alabama", "America/Chicago"},
+		{"AK", "Alaska", "America/Anchorage"},
+		{"AZ", "Arizona", "America/Phoenix"},
+		{"AR", "Arkansas", "America/Chicago"},
+		{"CA", "California", "America/Los_Angeles"},
+		{"CO", "Colorado", "America/Denver"},
+		{"CT", "Connecticut", "America/New_York"},
+		{"DE", "Delaware", "America/New_York"},
+		{"DC", "District of Columbia", "America/New_York"},
+		{"FL", "Florida", "America/New_York"},
+		{"GA", "Georgia", "America/New_York"},
+		{"HI", "Hawaii", "Pacific/Honolulu"},
+		{"ID", "Idaho", "America/Boise"},
+		{"IL", "Illinois", "America/Chicago"},
+		{"IN", "Indiana", "America/Indiana/Indianapolis"},
+		{"IA", "Iowa", "America/Chicago"},
+		{"KS", "Kansas", "America/Chicago"},
+		{"KY", "Kentucky", "America/New_York"},
+		{"LA", "Louisiana", "America/Chicago"},
+		{"ME", "Maine", "America/New_York"},
+		{"MD", "Maryland", "America/New_York"},
+		{"MA", "Massachusetts", "America/New_York"},
+		{"MI", "Michigan", "America/Detroit"},
+		{"MN", "Minnesota", "America/Chicago"},
+		{"MS", "Mississippi", "America/Chicago"},
+		{"MO", "Missouri", "America/Chicago"},
+		{"MT", "Montana", "America/Denver"},
+		{"NE", "Nebraska", "America/Chicago"},
+		{"NV", "Nevada", "America/Los_Angeles"},
+		{"NH", "New Hampshire", "America/New_York"},
+		{"NJ", "New Jersey", "America/New_York"},
+		{"NM", "New Mexico", "America/Denver"},
+		{"NY", "New York", "America/New_York"},
+		{"NC", "North Carolina", "America/New_York"},
+		{"ND", "North Dakota", "America/Chicago"},
+		{"OH", "Ohio", "America/New_York"},
+		{"OK", "Oklahoma", "America/Chicago"},
+		{"OR", "Oregon", "America/Los_Angeles"},
+		{"PA", "Pennsylvania", "America/New_York"},
+		{"RI", "Rhode Island", "America/New_York"},
+		{"SC", "South Carolina", "America/New_York"},
+		{"SD", "South Dakota", "America/Chicago"},
+		{"TN", "Tennessee", "America/Chicago"},
+		{"TX", "Texas", "America/Chicago"},
+		{"UT", "Utah", "America/Denver"},
+		{"VT", "Vermont", "America/New_York"},
+		{"VA", "Virginia", "America/New_York"},
+		{"WA", "Washington", "America/Los_Angeles"},
+		{"WV", "West Virginia", "America/New_York"},
+		{"WI", "Wisconsin", "America/Chicago"},
+		{"WY", "Wyoming", "America/Denver"},
+		{"AS", "American Samoa", "Pacific/Pago_Pago"},
+		{"GU", "Guam", "Pacific/Guam"},
+		{"MP", "Northern Mariana Islands", "Pacific/Saipan"},
+		{"PR", "Puerto Rico", "America/Puerto_Rico"},
+		{"VI", "Virgin Islands", "America/St_Thomas"},
+	} {
+		stateTimeZones[strings.ToLower(state.code)] = state.timeZone
+		stateTimeZones[strings.ToLower(state.name)] = state.timeZone
+	}
+}
+
+// timeZoneLocations caches the loaded time zones, keyed by name
+var timeZoneLocations sync.Map
+
+// StateTimeZone returns the time zone of a US state or territory, given by its
+// two letter code (e.g. "NY") or its name (e.g. "New York")
+func StateTimeZone(state string) (*time.Location, error) {
+	name, ok := stateTimeZones[strings.ToLower(strings.TrimSpace(state))]
+	if !ok {
+		return nil, fmt.Errorf("unknown state: %q", state)
+	}
+
+	if location, ok := timeZoneLocations.Load(name); ok {
+		return location.(*time.Location), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone for %s: %w", state, err)
+	}
+
+	timeZoneLocations.Store(name, location)
+
+	return location, nil
+}
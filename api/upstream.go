@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// defaultUserAgent is the User-Agent sent to upstream APIs that do not need
+// anything special, see webBrowserUserAgent for the one that does.
+const defaultUserAgent = "lilyfarmd (+https://lilyfarm.org)"
+
+// defaultHTTPClient is shared by the clients for the real upstream APIs. Callers are
+// expected to bound each request with a context deadline, the timeout here is
+// only a backstop for callers that do not.
+var defaultHTTPClient = &http.Client{Timeout: 2 * time.Minute}
+
+// ErrClientNotConfigured is returned when constructing a datasource whose upstream
+// client is not set in Options, e.g. because its credentials are missing
+var ErrClientNotConfigured = errors.New("client not configured")
+
+// UpstreamClient contains what is needed to make requests to an upstream API.
+// Every field can be replaced, e.g. to point a client at an httptest.Server.
+type UpstreamClient struct {
+	HTTPClient *http.Client   // client used to make requests, http.DefaultClient if nil
+	BaseURL    string         // URL of the endpoint the requests are made to
+	UserAgent  string         // value of the User-Agent header, omitted if empty
+	Header     http.Header    // other headers sent with every request, e.g. API keys
+	Guard      *UpstreamGuard // retries, rate limits and circuit breaker, a single attempt if nil
+}
+
+// statusError is returned when an upstream API responds with a status other than
+// http.StatusOK
+type statusError struct {
+	code int
+}
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("incorrect status code %v", err.code)
+}
+
+// get makes a GET request to the BaseURL with the given query parameters and returns
+// the body of the response, or an error if the request failed or did not return
+// http.StatusOK. GET requests are idempotent, so the Guard may make several attempts.
+func (client UpstreamClient) get(ctx context.Context, query url.Values, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.BaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	if client.UserAgent != "" {
+		req.Header.Set("User-Agent", client.UserAgent)
+	}
+
+	for name, values := range client.Header {
+		req.Header[name] = values
+	}
+
+	if client.Guard == nil {
+		return client.do(req)
+	}
+
+	return client.Guard.do(ctx, func(ctx context.Context) ([]byte, error) {
+		return client.do(req.Clone(ctx))
+	})
+}
+
+// do makes a single attempt at req
+func (client UpstreamClient) do(req *http.Request) ([]byte, error) {
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, statusError{code: res.StatusCode}
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	return body, nil
+}
+
+// Options contains the upstream clients, the Geocoder and the settings the
+// datasources in the registry are constructed with.
+type Options struct {
+	USDA     *USDAClient
+	NewYork  *NewYorkClient
+	GeoNames *GeoNamesClient // optional, only used as a Geocoder
+
+	// Geocoder converts zip codes into locations for NearestNByZipCode
+	Geocoder Geocoder
+
+	// USDAMaxMiles is the widest radius, in miles, the USDA datasource searches,
+	// whether looking for n markets or within a radius or bounds, DefaultUSDAMaxMiles if zero
+	USDAMaxMiles int
+
+	// USDACache configures the cache of the responses of the USDA API, responses
+	// are not cached if it is the zero value
+	USDACache USDACacheOptions
+
+	// Datasources are the names of the datasources being served, the "auto" and "all"
+	// datasources only choose from and merge those. Every registered datasource is
+	// served if empty.
+	Datasources []string
+
+	// Apis are the FarmersMarketApis already constructed with these options, by
+	// datasource name. The "auto" and "all" datasources query those rather than
+	// constructing their own, so that every dataset is downloaded, cached and refreshed
+	// once and the markets they return can be looked up in the datasource they come from.
+	Apis map[string]FarmersMarketApi
+}
+
+// serves returns true if the datasource with the given name is being served
+func (options Options) serves(name string) bool {
+	return len(options.Datasources) == 0 || slices.Contains(options.Datasources, name)
+}
+
+// datasourceApi returns the FarmersMarketApi of datasource in Apis, or constructs one
+func (options Options) datasourceApi(datasource Datasource) (FarmersMarketApi, error) {
+	if fmApi, ok := options.Apis[datasource.Name]; ok {
+		return fmApi, nil
+	}
+
+	return datasource.New(options)
+}
+
+// NewDefaultOptions returns Options with clients for the real upstream APIs, using
+// the credentials in the environment, and a Geocoder asking the geocoders in
+// DefaultGeocoderOrder. The credentials are optional. Without the USDA credentials
+// there is no USDA client, so the USDA datasource cannot be served, and without the
+// geonames credentials zip codes are only looked up in the embedded gazetteer.
+func NewDefaultOptions() (Options, error) {
+	options := Options{
+		NewYork: NewNewYorkClient(sharedNewYorkGuard),
+	}
+
+	if err := DefaultCredentials.LoadUSDACredentials(); err == nil {
+		options.USDA = NewUSDAClient(DefaultCredentials.usda, sharedUSDAGuard)
+	}
+
+	if err := DefaultCredentials.LoadGeoNamesCredentials(); err == nil {
+		options.GeoNames = NewGeoNamesClient(DefaultCredentials.geonames, sharedGeoNamesGuard)
+	}
+
+	geocoder, err := NewGeocoderChain(DefaultGeocoderOrder, options)
+	if err != nil {
+		return Options{}, err
+	}
+
+	options.Geocoder = geocoder
+
+	return options, nil
+}
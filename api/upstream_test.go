@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUSDAClientFetchByLocationAndRadius(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		assert.Equal(t, "key", query.Get("apikey"))
+		assert.Equal(t, "-73.98", query.Get("x"))
+		assert.Equal(t, "40.78", query.Get("y"))
+		assert.Equal(t, "25", query.Get("radius"))
+		assert.Equal(t, webBrowserUserAgent, r.Header.Get("User-Agent"))
+
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer server.Close()
+
+	client := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	client.HTTPClient = server.Client()
+	client.BaseURL = server.URL
+
+	body, err := client.FetchByLocationAndRadius(context.Background(), geography.HaversinePoint{Latitude: 40.78, Longitude: -73.98}, 25)
+	require.Nil(t, err)
+	require.Equal(t, `{"data": []}`, string(body))
+}
+
+func TestUSDAWithinRadiusRoundsUpToWholeMiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "4", r.URL.Query().Get("radius"))
+
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer server.Close()
+
+	client := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	client.BaseURL = server.URL
+
+	fmApi, err := NewUSDAFarmersMarketApi(Options{USDA: client, Geocoder: GeocoderChain{}})
+	require.Nil(t, err)
+
+	records, err := fmApi.WithinRadius(context.Background(), geography.HaversinePoint{Latitude: 40.78, Longitude: -73.98}, geography.MilesToMeters(3.2))
+	require.Nil(t, err)
+	require.Empty(t, records)
+}
+
+func TestUSDAClientIncorrectStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	client.BaseURL = server.URL
+
+	_, err := client.FetchByLocationAndRadius(context.Background(), geography.HaversinePoint{}, 25)
+	require.NotNil(t, err)
+}
+
+func TestNewYorkClientFetchRows(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	// The New York State dataset is served from its Socrata portal
+	client := NewNewYorkClient(NewUpstreamGuard("newyork", SocrataUpstreamPolicy))
+	require.Equal(t, "https://data.ny.gov/resource/farmersmarkets.json", client.BaseURL)
+
+	client.BaseURL = server.URL
+
+	rows, err := client.FetchRows(context.Background())
+	require.Nil(t, err)
+	require.Empty(t, rows)
+}
+
+func TestGeoNamesClientZipCodeToHaversinePoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		assert.Equal(t, "user", query.Get("username"))
+		assert.Equal(t, "US", query.Get("country"))
+
+		if query.Get("postalcode") != "77001" {
+			w.Write([]byte(`{"postalcodes": []}`))
+			return
+		}
+
+		w.Write([]byte(`{"postalcodes": [{"postalcode": "77001", "lat": 29.81, "lng": -95.31}]}`))
+	}))
+	defer server.Close()
+
+	client := NewGeoNamesClient("user", NewUpstreamGuard("geonames", GeoNamesUpstreamPolicy))
+	client.BaseURL = server.URL
+
+	point, err := client.ZipCodeToHaversinePoint(context.Background(), "77001")
+	require.Nil(t, err)
+	require.Equal(t, geography.HaversinePoint{Latitude: 29.81, Longitude: -95.31}, point)
+
+	_, err = client.ZipCodeToHaversinePoint(context.Background(), "00000")
+	require.NotNil(t, err)
+}
+
+func TestUpstreamClientCancelledContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewNewYorkClient(NewUpstreamGuard("newyork", SocrataUpstreamPolicy))
+	client.BaseURL = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.FetchRows(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jadidbourbaki/gofarm/geography"
 )
@@ -19,59 +25,89 @@ const webBrowserUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:124.0) Gecko/201
 // usdaDefaultMiles is the default range to return Farmers' Markets in for the USDA API
 const usdaDefaultMiles = 100
 
-// FetchUSDADataByLocation fetches the data from the USDA API endpoint
-// location is the location to center the dataset on
-// The radius it uses is api.usdaDefaultMiles
-func FetchUSDADataByLocation(location geography.HaversinePoint) ([]byte, error) {
-	return FetchUSDADataByLocationAndRadius(location, usdaDefaultMiles)
+// DefaultUSDAMaxMiles is the widest range asked of the USDA API, see Options.USDAMaxMiles
+const DefaultUSDAMaxMiles = 500
+
+// usdaRememberedListings is the number of listings USDAFarmersMarketApi.Market finds
+// without asking the USDA again
+const usdaRememberedListings = 10000
+
+// usdaMarketMiles is the radius around the location in its ID that
+// USDAFarmersMarketApi.Market asks the USDA for a listing in
+const usdaMarketMiles = 1
+
+// usdaLocationSeparator separates the location of a listing from the rest of its
+// MarketID, see usdaMarketID
+const usdaLocationSeparator = "@"
+
+// USDAFarmersMarketAPIEndpoint is the public endpoint for the USDA Farmers' Market API
+const USDAFarmersMarketAPIEndpoint = "https://www.usdalocalfoodportal.com/api/farmersmarket"
+
+// USDAListingImageBaseURL is where the USDA serves the listing images named in USDARecord.ListingImage
+var USDAListingImageBaseURL = "https://www.usdalocalfoodportal.com/mywebsite/uploads/"
+
+// usdaPlaceholderImagePrefix starts the names of the stock images of listings without one
+const usdaPlaceholderImagePrefix = "default-"
+
+// USDAClient is a client for the USDA Farmers' Market API
+type USDAClient struct {
+	UpstreamClient
+	APIKey string
+}
+
+// NewUSDAClient returns a client for the public USDA Farmers' Market API endpoint. The
+// guard should be shared by every client of the API, see USDAUpstreamPolicy, a single
+// attempt is made at every request if it is nil.
+func NewUSDAClient(apiKey string, guard *UpstreamGuard) *USDAClient {
+	return &USDAClient{
+		UpstreamClient: UpstreamClient{
+			HTTPClient: defaultHTTPClient,
+			BaseURL:    USDAFarmersMarketAPIEndpoint,
+			// The USDA Farmer's Market API seems to return an http.StatusForbidden unless
+			// a User-Agent is specified.
+			UserAgent: webBrowserUserAgent,
+			Guard:     guard,
+		},
+		APIKey: apiKey,
+	}
 }
 
-// FetchUSDADataByLocationAndRadius fetches the data from the USDA API endpoint
+// FetchByLocationAndRadius fetches the data from the USDA API endpoint
 // location is the location to center the dataset on
 // radius is the radius to fetch the data within, in miles
 // From experimentation, it seems like the results return in ascending
 // order of distance from the provided location.
-func FetchUSDADataByLocationAndRadius(location geography.HaversinePoint, radius int) ([]byte, error) {
-	const url = "https://www.usdalocalfoodportal.com/api/farmersmarket"
-
+func (client *USDAClient) FetchByLocationAndRadius(ctx context.Context, location geography.HaversinePoint, radius int) ([]byte, error) {
 	logPrefix := "Fetching USDA Data"
 
-	apiKey := DefaultCredentials.usda
+	query := url.Values{}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", logPrefix, err)
-	}
-
-	query := req.URL.Query()
-
-	query.Add("apikey", apiKey)
+	query.Add("apikey", client.APIKey)
 	query.Add("x", fmt.Sprint(location.Longitude))
 	query.Add("y", fmt.Sprint(location.Latitude))
 	query.Add("radius", fmt.Sprint(radius))
 
-	req.URL.RawQuery = query.Encode()
-
-	// The USDA Farmer's Market API seems to return an http.StatusForbidden unless
-	// a User-Agent is specified.
-	req.Header.Set("User-Agent", webBrowserUserAgent)
-
-	res, err := http.DefaultClient.Do(req)
+	bodyBytes, err := client.get(ctx, query, "")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", logPrefix, err)
 	}
 
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: incorrect status code %v", logPrefix, res.StatusCode)
-	}
+	return bodyBytes, nil
+}
 
-	bodyBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", logPrefix, err)
-	}
+// FetchUSDADataByLocation fetches the data from the USDA API endpoint
+// location is the location to center the dataset on
+// The radius it uses is api.usdaDefaultMiles
+func FetchUSDADataByLocation(ctx context.Context, location geography.HaversinePoint) ([]byte, error) {
+	return FetchUSDADataByLocationAndRadius(ctx, location, usdaDefaultMiles)
+}
 
-	return bodyBytes, nil
+// FetchUSDADataByLocationAndRadius fetches the data from the public USDA API endpoint
+// using DefaultCredentials. See USDAClient.FetchByLocationAndRadius.
+func FetchUSDADataByLocationAndRadius(ctx context.Context, location geography.HaversinePoint, radius int) ([]byte, error) {
+	client := NewUSDAClient(DefaultCredentials.usda, sharedUSDAGuard)
+
+	return client.FetchByLocationAndRadius(ctx, location, radius)
 }
 
 // USDARecord is a structure to unmarshal the json received from the USDA API
@@ -106,14 +142,11 @@ type USDARecord struct {
 	UpdateTime      string `json:"updatetime"`
 }
 
+// FarmersMarketRecord converts a USDARecord to a FarmersMarketRecord. The distance
+// reported by the USDA (in miles) is not used, see "Note on Distance" in FarmersMarketRecord.
 func (record USDARecord) FarmersMarketRecord() (FarmersMarketRecord, error) {
 	emptyRecord := FarmersMarketRecord{}
 
-	distance, err := strconv.ParseFloat(record.Distance, 64)
-	if err != nil {
-		return emptyRecord, fmt.Errorf("distance malformatted: %w", err)
-	}
-
 	latitude, err := strconv.ParseFloat(record.LocationY, 64)
 	if err != nil {
 		return emptyRecord, fmt.Errorf("latitude malformatted: %w", err)
@@ -124,24 +157,101 @@ func (record USDARecord) FarmersMarketRecord() (FarmersMarketRecord, error) {
 		return emptyRecord, fmt.Errorf("longitude malformatted: %w", err)
 	}
 
+	description := record.BriefDesc
+	if description == "" {
+		description = record.ListingDesc
+	}
+
+	var contact *FarmersMarketContact
+	if record.ContactName != "" || record.ContactEmail != "" || record.ContactPhone != "" {
+		contact = &FarmersMarketContact{Name: record.ContactName, Email: record.ContactEmail, Phone: record.ContactPhone}
+	}
+
+	socialMedia := &FarmersMarketSocialMedia{
+		Facebook:  record.MediaFacebook,
+		Instagram: record.MediaInstagram,
+		Twitter:   record.MediaTwitter,
+		Pinterest: record.MediaPinterest,
+		Youtube:   record.MediaYoutube,
+		Blog:      record.MediaBlog,
+	}
+	if *socialMedia == (FarmersMarketSocialMedia{}) {
+		socialMedia = nil
+	}
+
+	// A listing that was never updated is still a listing
+	var lastUpdated *time.Time
+	if updated, err := ParseUSDAUpdateTime(record.UpdateTime); err == nil {
+		lastUpdated = &updated
+	}
+
+	location := geography.HaversinePoint{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+
 	return FarmersMarketRecord{
+		ID:          usdaMarketID(record.ListingID, record.ListingName, location),
 		Name:        record.ListingName,
-		Description: record.BriefDesc,
+		Description: description,
 		Address: FarmersMarketAddress{
 			Street:  record.LocationStreet,
 			City:    record.LocationCity,
 			State:   record.LocationState,
 			ZipCode: record.LocationZipcode,
 		},
-		Distance: distance,
-		Location: geography.HaversinePoint{
-			Latitude:  latitude,
-			Longitude: longitude,
-		},
-		Website: record.MediaWebsite,
+		Location:    location,
+		Website:     record.MediaWebsite,
+		Contact:     contact,
+		SocialMedia: socialMedia,
+		ImageURL:    record.imageURL(),
+		LastUpdated: lastUpdated,
+
+		// The distance is computed relative to the queried location later
+		Distance: -1,
 	}, nil
 }
 
+// imageURL returns the URL of the listing image, or an empty string if the listing
+// only has a placeholder image
+func (record USDARecord) imageURL() string {
+	if record.ListingImage == "" || strings.HasPrefix(record.ListingImage, usdaPlaceholderImagePrefix) {
+		return ""
+	}
+
+	image, err := url.Parse(record.ListingImage)
+	if err != nil {
+		return ""
+	}
+
+	if image.IsAbs() {
+		return image.String()
+	}
+
+	base, err := url.Parse(USDAListingImageBaseURL)
+	if err != nil {
+		return ""
+	}
+
+	return base.ResolveReference(image).String()
+}
+
+// usdaOrdinalSuffixPattern finds the suffix of the day in dates such as "Mar 27th, 2023"
+var usdaOrdinalSuffixPattern = regexp.MustCompile(`(\d)(?:st|nd|rd|th)\b`)
+
+// ParseUSDAUpdateTime parses the USDARecord.UpdateTime of a listing, a date such as
+// "Mar 27th, 2023", into midnight UTC of that day
+func ParseUSDAUpdateTime(updateTime string) (time.Time, error) {
+	date := usdaOrdinalSuffixPattern.ReplaceAllString(strings.TrimSpace(updateTime), "$1")
+
+	parsed, err := time.Parse("Jan 2, 2006", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("update time malformatted: %w", err)
+	}
+
+	return parsed, nil
+}
+
 // USDADataset is a structure to unamrshal the json received from the USDA API
 type USDADataset struct {
 	Data []USDARecord `json:"data"`
@@ -162,37 +272,123 @@ func ParseUSDADataset(dataset []byte) ([]USDARecord, error) {
 	return usdaDataset.Data, nil
 }
 
+// usdaMarketID returns the MarketID of a USDA listing, e.g. "usda:312052@42.36010_-71.05890".
+// The USDA API has no way of fetching a single listing, so the ID carries the location
+// of the listing for USDAFarmersMarketApi.Market to ask for the listings around it.
+func usdaMarketID(listingID string, name string, location geography.HaversinePoint) string {
+	id := MarketID("usda", listingID)
+	if listingID == "" {
+		id = hashedMarketID("usda", name, location)
+	}
+
+	return fmt.Sprintf("%s%s%.5f_%.5f", id, usdaLocationSeparator, location.Latitude, location.Longitude)
+}
+
+// usdaMarketLocation returns the location carried by the MarketID of a USDA listing
+func usdaMarketLocation(id string) (geography.HaversinePoint, error) {
+	_, coordinates, ok := strings.Cut(id, usdaLocationSeparator)
+	if !ok {
+		return geography.HaversinePoint{}, fmt.Errorf("%w: %s has no location", ErrMarketNotFound, id)
+	}
+
+	latitudeString, longitudeString, ok := strings.Cut(coordinates, "_")
+	if !ok {
+		return geography.HaversinePoint{}, fmt.Errorf("%w: %s has no location", ErrMarketNotFound, id)
+	}
+
+	latitude, err := strconv.ParseFloat(latitudeString, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return geography.HaversinePoint{}, fmt.Errorf("%w: %s has a malformed latitude", ErrMarketNotFound, id)
+	}
+
+	longitude, err := strconv.ParseFloat(longitudeString, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return geography.HaversinePoint{}, fmt.Errorf("%w: %s has a malformed longitude", ErrMarketNotFound, id)
+	}
+
+	return geography.HaversinePoint{Latitude: latitude, Longitude: longitude}, nil
+}
+
 // USDAFarmersMarketApi is the implementation of the FarmersMarketApi that uses the USDA API endpoint
 type USDAFarmersMarketApi struct {
+	client      *USDAClient
+	geocoder    Geocoder
+	metricSpace geography.MetricSpace
+	listings    *listingMemory     // the listings returned most recently, see Market
+	maxMiles    int                // the widest radius any query searches
+	responses   *usdaResponseCache // nil if responses are not cached
 }
 
-func NewUSDAFarmersMarketApi() (*USDAFarmersMarketApi, error) {
-	if err := DefaultCredentials.LoadGeoNamesCredentials(); err != nil {
-		return nil, err
+// usdaCapabilities are the capabilities of the USDA datasource
+var usdaCapabilities = DatasourceCapabilities{
+	HasSnapData:        false,
+	HasOperationHours:  false,
+	HasOperationSeason: false,
+	HasOperationMonths: false,
+	Live:               true,
+}
+
+func init() {
+	RegisterDatasource(Datasource{
+		Name:         "usda",
+		Title:        "United States Department of Agriculture (USDA)",
+		Coverage:     "Farmers' Markets across the United States listed in the USDA Local Food Directories",
+		Attribution:  "Data from the USDA Agricultural Marketing Service Local Food Portal (https://www.usdalocalfoodportal.com/). Works of the U.S. Government are in the public domain.",
+		Capabilities: usdaCapabilities,
+		New: func(options Options) (FarmersMarketApi, error) {
+			return NewUSDAFarmersMarketApi(options)
+		},
+	})
+}
+
+// NewUSDAFarmersMarketApi returns a pointer to a freshly constructed USDA Farmers Market Api.
+// It needs both the USDA client and the Geocoder to be set in options.
+func NewUSDAFarmersMarketApi(options Options) (*USDAFarmersMarketApi, error) {
+	if options.USDA == nil {
+		return nil, fmt.Errorf("usda %w", ErrClientNotConfigured)
 	}
 
-	if err := DefaultCredentials.LoadUSDACredentials(); err != nil {
+	if options.Geocoder == nil {
+		return nil, fmt.Errorf("geocoder not configured")
+	}
+
+	maxMiles := options.USDAMaxMiles
+	if maxMiles <= 0 {
+		maxMiles = DefaultUSDAMaxMiles
+	}
+
+	responses, err := newUSDAResponseCache(options.USDACache)
+	if err != nil {
 		return nil, err
 	}
 
-	return &USDAFarmersMarketApi{}, nil
+	return &USDAFarmersMarketApi{
+		client:      options.USDA,
+		geocoder:    options.Geocoder,
+		metricSpace: geography.DefaultHaversineMetricSpace,
+		listings:    newListingMemory(usdaRememberedListings),
+		maxMiles:    maxMiles,
+		responses:   responses,
+	}, nil
 }
 
 // Refresh does not really do anything for the USDA API as
 // all our data is collected live.
-func (api *USDAFarmersMarketApi) Refresh() error {
+func (api *USDAFarmersMarketApi) Refresh(_ context.Context) error {
 	return nil
 }
 
-func (api *USDAFarmersMarketApi) NearestN(n int, location geography.HaversinePoint) ([]FarmersMarketRecord, error) {
-	dataset, err := FetchUSDADataByLocation(location)
+// fetchRecords asks the USDA for the records within radius miles of location, and
+// returns them along with the size of the response. Their Distance is not set.
+func (api *USDAFarmersMarketApi) fetchRecords(ctx context.Context, location geography.HaversinePoint, radius int) ([]FarmersMarketRecord, int, error) {
+	dataset, err := api.client.FetchByLocationAndRadius(ctx, location, radius)
 	if err != nil {
-		return nil, fmt.Errorf("fetching usda data: %w", err)
+		return nil, 0, fmt.Errorf("fetching usda data: %w", err)
 	}
 
 	parsedDataset, err := ParseUSDADataset(dataset)
 	if err != nil {
-		return nil, fmt.Errorf("parsing usda data: %w", err)
+		return nil, 0, fmt.Errorf("parsing usda data: %w", err)
 	}
 
 	generalizedDataset := []FarmersMarketRecord{}
@@ -201,43 +397,226 @@ func (api *USDAFarmersMarketApi) NearestN(n int, location geography.HaversinePoi
 		generalizedRecord, err := record.FarmersMarketRecord()
 
 		if err != nil {
-			return nil, fmt.Errorf("converting to farmers market record: %w", err)
+			return nil, 0, fmt.Errorf("converting to farmers market record: %w", err)
+		}
+
+		generalizedDataset = append(generalizedDataset, generalizedRecord)
+	}
+
+	api.listings.remember(generalizedDataset)
+
+	return generalizedDataset, len(dataset), nil
+}
+
+// fetchNear returns the records the USDA lists within radius miles of location,
+// with their Distance set relative to location, in ascending order of distance.
+// The records come from the response cache if there is one. When they were
+// fetched is added to the QueryReport of ctx.
+func (api *USDAFarmersMarketApi) fetchNear(ctx context.Context, location geography.HaversinePoint, radius int) ([]FarmersMarketRecord, error) {
+	var fetchedDataset []FarmersMarketRecord
+	var fetchedAt time.Time
+	var err error
+
+	if api.responses != nil {
+		fetchedDataset, fetchedAt, err = api.responses.fetch(ctx, location, radius, api.fetchRecords)
+	} else {
+		fetchedAt = time.Now()
+		fetchedDataset, _, err = api.fetchRecords(ctx, location, radius)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	reportDataAsOf(ctx, fetchedAt)
+
+	generalizedDataset := []FarmersMarketRecord{}
+
+	// The records are copied, cached ones are shared with other queries
+	for _, generalizedRecord := range fetchedDataset {
+		distance, err := api.metricSpace.Distance(generalizedRecord.HaversinePoint(), location)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute distance: %w", err)
+		}
+
+		// A cached response covers a wider area than asked for
+		if api.responses != nil && distance > geography.MilesToMeters(float64(radius)) {
+			continue
 		}
 
+		generalizedRecord.Distance = distance
+		generalizedRecord.DistanceUnits = geography.Meters
+
 		generalizedDataset = append(generalizedDataset, generalizedRecord)
 	}
 
+	// The USDA already returns records in ascending order of its own distance,
+	// sort them again so the order agrees with the distances we computed.
+	slices.SortStableFunc(generalizedDataset, func(a, b FarmersMarketRecord) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+
+	return generalizedDataset, nil
+}
+
+// NearestN asks the USDA for the markets within usdaDefaultMiles of location. Until
+// it has n markets selected by filter, it asks again with twice the radius, up to
+// the widest radius configured.
+func (api *USDAFarmersMarketApi) NearestN(ctx context.Context, n int, location geography.HaversinePoint, filter Filter) ([]FarmersMarketRecord, error) {
+	if err := filter.supportedBy("usda", usdaCapabilities); err != nil {
+		return nil, err
+	}
+
+	// The only negative value we accept is -1
+	if n < -1 {
+		return nil, fmt.Errorf("invalid value for n")
+	}
+
+	radius := min(usdaDefaultMiles, api.maxMiles)
+
 	var records []FarmersMarketRecord
 
+	for {
+		fetchedDataset, err := api.fetchNear(ctx, location, radius)
+		if err != nil {
+			return nil, err
+		}
+
+		// Filter before truncating to n
+		records = []FarmersMarketRecord{}
+		for _, record := range fetchedDataset {
+			if filter.Matches(record) {
+				records = append(records, record)
+			}
+		}
+
+		if n == -1 || len(records) >= n || radius >= api.maxMiles {
+			break
+		}
+
+		radius = min(2*radius, api.maxMiles)
+	}
+
 	if n == -1 {
-		n = len(generalizedDataset)
+		return records, nil
+	}
+
+	if n > len(records) {
+		reportWarning(ctx, "found %d of the %d markets requested within %d miles", len(records), n, radius)
+		return records, nil
+	}
+
+	return records[:n], nil
+}
+
+func (api *USDAFarmersMarketApi) NearestNByZipCode(ctx context.Context, n int, zipcode string, filter Filter) ([]FarmersMarketRecord, error) {
+	location, err := api.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("zip code lookup failed: %w", err)
+	}
+
+	return api.NearestN(ctx, n, location, filter)
+}
+
+// clampMiles rounds miles up to the whole number of miles asked of the USDA, at least
+// one and at most the widest radius configured. Narrowing the radius is reported as a
+// warning, since the markets beyond it are left out.
+func (api *USDAFarmersMarketApi) clampMiles(ctx context.Context, miles float64) int {
+	if miles > float64(api.maxMiles) {
+		reportWarning(ctx, "only searched within %d miles, markets further away were left out", api.maxMiles)
+		return api.maxMiles
 	}
 
-	// The only negative value we accept is -1, which is handled above
-	if n < 0 {
-		return records, fmt.Errorf("invalid value for n")
+	return max(int(math.Ceil(miles)), 1)
+}
+
+// WithinRadius asks the USDA for the markets within the radius rounded up to whole
+// miles, and then drops the ones that turn out to be further than distance away. The
+// radius is narrowed to the widest radius configured, see clampMiles.
+func (api *USDAFarmersMarketApi) WithinRadius(ctx context.Context, location geography.HaversinePoint, distance float64) ([]FarmersMarketRecord, error) {
+	if distance < 0 || math.IsNaN(distance) {
+		return nil, fmt.Errorf("invalid value for distance")
 	}
 
-	minLen := min(len(generalizedDataset), n)
+	radius := api.clampMiles(ctx, geography.MetersToMiles(distance))
 
-	for i := 0; i < minLen; i++ {
-		records = append(records, generalizedDataset[i])
+	generalizedDataset, err := api.fetchNear(ctx, location, radius)
+	if err != nil {
+		return nil, err
 	}
 
-	if n > minLen {
-		return records, fmt.Errorf("did not find all n records")
+	records := []FarmersMarketRecord{}
+	for _, record := range generalizedDataset {
+		if record.Distance <= distance {
+			records = append(records, record)
+		}
 	}
 
 	return records, nil
 }
 
-func (api *USDAFarmersMarketApi) NearestNByZipCode(n int, zipcode string) ([]FarmersMarketRecord, error) {
-	location, err := ZipCodeToHaversinePoint(zipcode)
+func (api *USDAFarmersMarketApi) WithinRadiusByZipCode(ctx context.Context, zipcode string, distance float64) ([]FarmersMarketRecord, error) {
+	location, err := api.geocoder.ZipCodeToHaversinePoint(ctx, zipcode)
 	if err != nil {
 		return nil, fmt.Errorf("zip code lookup failed: %w", err)
 	}
 
-	return api.NearestN(n, location)
+	return api.WithinRadius(ctx, location, distance)
+}
+
+// WithinBounds asks the USDA for the markets within the circle around the box, and
+// then drops the ones outside of it. The circle is narrowed to the widest radius
+// configured, see clampMiles.
+func (api *USDAFarmersMarketApi) WithinBounds(ctx context.Context, southWest geography.HaversinePoint, northEast geography.HaversinePoint, limit int) ([]FarmersMarketRecord, error) {
+	box, err := geography.NewBoundingBox(southWest, northEast)
+	if err != nil {
+		return nil, fmt.Errorf("within bounds: %w", err)
+	}
+
+	radius := api.clampMiles(ctx, geography.MetersToMiles(box.Radius()))
+
+	generalizedDataset, err := api.fetchNear(ctx, box.Center(), radius)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []FarmersMarketRecord{}
+	for _, record := range generalizedDataset {
+		if box.Contains(record.HaversinePoint()) {
+			records = append(records, record)
+		}
+	}
+
+	return limitRecords(records, limit)
+}
+
+// Market returns a market the USDA listed in a recent query. The USDA API has no way
+// of fetching a single listing, so the listings around the location carried by the ID
+// of any other market are asked for, see usdaMarketID.
+func (api *USDAFarmersMarketApi) Market(ctx context.Context, id string) (FarmersMarketRecord, error) {
+	if record, ok := api.listings.recall(id); ok {
+		return record, nil
+	}
+
+	location, err := usdaMarketLocation(id)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	records, err := api.fetchNear(ctx, location, usdaMarketMiles)
+	if err != nil {
+		return FarmersMarketRecord{}, err
+	}
+
+	for _, record := range records {
+		if record.ID == id {
+			record.Distance = -1
+			record.DistanceUnits = ""
+			return record, nil
+		}
+	}
+
+	return FarmersMarketRecord{}, fmt.Errorf("%w: %s", ErrMarketNotFound, id)
 }
 
 // Verify that USDAFarmersMarketApi implements FarmersMarketApi
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"golang.org/x/sync/singleflight"
+)
+
+// USDACacheOptions configures the cache of the responses of the USDA API. Queries
+// from locations in the same geohash cell, for the same radius, share a response.
+type USDACacheOptions struct {
+	Precision int           // number of characters of the geohash cells, see geography.Geohash
+	TTL       time.Duration // how long a response is cached for, not cached if zero
+	MaxBytes  int           // maximum size of the cached responses, as downloaded
+
+	// MaxStale is how long after expiring a response is still served while the USDA
+	// cannot be reached
+	MaxStale time.Duration
+}
+
+// DefaultUSDACacheOptions returns the options the service uses unless told otherwise.
+// Cells of 5 characters are about 5 km wide, so the radius asked for only grows by a
+// few miles, and the USDA listings change rarely enough to be kept for a few hours,
+// or a day when the USDA is down.
+func DefaultUSDACacheOptions() USDACacheOptions {
+	return USDACacheOptions{
+		Precision: 5,
+		TTL:       6 * time.Hour,
+		MaxBytes:  64 << 20,
+		MaxStale:  24 * time.Hour,
+	}
+}
+
+// usdaCacheEntry is the converted records of a response of the USDA API
+type usdaCacheEntry struct {
+	key       string
+	records   []FarmersMarketRecord
+	size      int // of the response, as downloaded
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+// usdaFetch asks the USDA API for the records within radius miles of location, and
+// returns them along with the size of the response
+type usdaFetch func(ctx context.Context, location geography.HaversinePoint, radius int) ([]FarmersMarketRecord, int, error)
+
+// usdaResponseCache is a least recently used cache of the responses of the USDA API,
+// keyed by the geohash cell of the location asked about and the radius. On a miss, the
+// USDA is asked about the center of the cell with the radius grown by the distance to
+// its corners, so that the response lists every market within the radius of any
+// location in the cell.
+type usdaResponseCache struct {
+	options USDACacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // values are *usdaCacheEntry
+	order   *list.List               // front is the most recently used
+	bytes   int
+
+	fetches singleflight.Group // concurrent misses for the same key share a fetch
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// newUSDAResponseCache returns an empty cache, or nil if options.TTL is zero
+func newUSDAResponseCache(options USDACacheOptions) (*usdaResponseCache, error) {
+	if options.TTL <= 0 {
+		return nil, nil
+	}
+
+	if options.Precision <= 0 {
+		return nil, fmt.Errorf("usda cache precision must be positive")
+	}
+
+	if options.MaxBytes <= 0 {
+		return nil, fmt.Errorf("usda cache size must be positive")
+	}
+
+	return &usdaResponseCache{
+		options: options,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}, nil
+}
+
+// get returns the cached entry for key, or nil if there is none, and whether it has
+// not expired yet. Expired entries are kept for MaxStale in case the USDA is down.
+func (cache *usdaResponseCache) get(key string) (*usdaCacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	now := cache.now()
+
+	entry := element.Value.(*usdaCacheEntry)
+	if !entry.expiresAt.Add(cache.options.MaxStale).After(now) {
+		cache.remove(element)
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+
+	return entry, entry.expiresAt.After(now)
+}
+
+// put caches entry, forgetting the least recently used entries until the cache fits
+// in its size. Entries larger than the whole cache are not cached.
+func (cache *usdaResponseCache) put(entry *usdaCacheEntry) {
+	if entry.size > cache.options.MaxBytes {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[entry.key]; ok {
+		cache.remove(element)
+	}
+
+	cache.entries[entry.key] = cache.order.PushFront(entry)
+	cache.bytes += entry.size
+
+	for cache.bytes > cache.options.MaxBytes {
+		cache.remove(cache.order.Back())
+	}
+}
+
+// remove forgets a cached entry, the caller must hold mu
+func (cache *usdaResponseCache) remove(element *list.Element) {
+	entry := cache.order.Remove(element).(*usdaCacheEntry)
+	delete(cache.entries, entry.key)
+	cache.bytes -= entry.size
+}
+
+// fetch returns the records within radius miles of location, from the cache or from
+// fetch, and when they were fetched. The records may include markets further away, the
+// caller has to drop them. If fetch fails, or does not finish before the deadline of
+// ctx, an expired response is returned instead if there is one, with a warning added
+// to the QueryReport of ctx.
+func (cache *usdaResponseCache) fetch(ctx context.Context, location geography.HaversinePoint, radius int, fetch usdaFetch) ([]FarmersMarketRecord, time.Time, error) {
+	cell := geography.Geohash(location, cache.options.Precision)
+	key := fmt.Sprintf("%s/%d", cell, radius)
+
+	cached, fresh := cache.get(key)
+	if fresh {
+		return cached.records, cached.fetchedAt, nil
+	}
+
+	stale := func(err error) ([]FarmersMarketRecord, time.Time, error) {
+		if cached == nil || errors.Is(err, context.Canceled) {
+			return nil, time.Time{}, err
+		}
+
+		reportWarning(ctx, "the usda could not be reached, returned the markets it listed at %s", cached.fetchedAt.UTC().Format(time.RFC3339))
+
+		return cached.records, cached.fetchedAt, nil
+	}
+
+	result := cache.fetches.DoChan(key, func() (interface{}, error) {
+		box, err := geography.GeohashBounds(cell)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every location in the cell is at most box.Radius() away from its center
+		padding := int(math.Ceil(geography.MetersToMiles(box.Radius())))
+
+		fetchedAt := cache.now()
+
+		// Shared by every caller waiting for it, so not cancelled when any single one
+		// of them gives up, but still bounded by the deadline of the first one
+		fetchCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+			defer cancel()
+		}
+
+		records, size, err := fetch(fetchCtx, box.Center(), radius+padding)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &usdaCacheEntry{
+			key:       key,
+			records:   records,
+			size:      size,
+			fetchedAt: fetchedAt,
+			expiresAt: fetchedAt.Add(cache.options.TTL),
+		}
+		cache.put(entry)
+
+		return entry, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return stale(ctx.Err())
+	case fetched := <-result:
+		if fetched.Err != nil {
+			return stale(fetched.Err)
+		}
+
+		entry := fetched.Val.(*usdaCacheEntry)
+		return entry.records, entry.fetchedAt, nil
+	}
+}
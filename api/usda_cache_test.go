@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+const usdaCacheTestResponse = `{"data": [
+	{"listing_id": "1", "listing_name": "West", "location_x": "-73.770", "location_y": "42.650"},
+	{"listing_id": "2", "listing_name": "East", "location_x": "-73.745", "location_y": "42.650"}
+]}`
+
+// newTestCachedUSDAApi returns a USDA datasource with a response cache in front of a
+// stand-in, and the queries the stand-in received
+func newTestCachedUSDAApi(t *testing.T, maxBytes int) (*USDAFarmersMarketApi, *[]url.Values) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Write([]byte(usdaCacheTestResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	usda := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	usda.BaseURL = server.URL
+
+	fmApi, err := NewUSDAFarmersMarketApi(Options{
+		USDA:      usda,
+		Geocoder:  GeocoderChain{},
+		USDACache: USDACacheOptions{Precision: 5, TTL: time.Hour, MaxBytes: maxBytes},
+	})
+	require.Nil(t, err)
+
+	return fmApi, &queries
+}
+
+func TestUSDAResponseCache(t *testing.T) {
+	fmApi, queries := newTestCachedUSDAApi(t, 1<<20)
+
+	now := time.Date(2024, time.May, 4, 12, 0, 0, 0, time.UTC)
+	fmApi.responses.now = func() time.Time { return now }
+
+	// Two locations about 1.5 km apart in the same geohash cell
+	west := geography.HaversinePoint{Latitude: 42.650, Longitude: -73.765}
+	east := geography.HaversinePoint{Latitude: 42.655, Longitude: -73.746}
+	require.Equal(t, geography.Geohash(west, 5), geography.Geohash(east, 5))
+
+	ctx, report := WithQueryReport(context.Background())
+	records, err := fmApi.NearestN(ctx, -1, west, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"West", "East"}, []string{records[0].Name, records[1].Name})
+	require.Equal(t, now, report.DataAsOf())
+
+	// The USDA is asked about the center of the cell, a little further than asked for
+	require.Equal(t, 1, len(*queries))
+	require.Equal(t, "102", (*queries)[0].Get("radius"))
+	require.Equal(t, "42.64892578125", (*queries)[0].Get("y"))
+
+	// The second location is served from the cache, sorted by the distance from it
+	records, err = fmApi.NearestN(context.Background(), -1, east, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"East", "West"}, []string{records[0].Name, records[1].Name})
+	require.Equal(t, 1, len(*queries))
+
+	expected, err := geography.DefaultHaversineMetricSpace.Distance(east, records[0].HaversinePoint())
+	require.Nil(t, err)
+	require.Equal(t, expected, records[0].Distance)
+
+	// Markets the cached response lists outside of the radius are dropped. East is
+	// about a mile and a quarter from west.
+	records, err = fmApi.WithinRadius(context.Background(), west, geography.MilesToMeters(1))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "West", records[0].Name)
+	require.Equal(t, 2, len(*queries))
+
+	// Expired responses are fetched again
+	now = now.Add(2 * time.Hour)
+	_, err = fmApi.NearestN(context.Background(), -1, west, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 3, len(*queries))
+}
+
+func TestUSDAResponseCacheSize(t *testing.T) {
+	// Room for a single response
+	fmApi, queries := newTestCachedUSDAApi(t, len(usdaCacheTestResponse)+1)
+
+	_, err := fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+
+	_, err = fmApi.WithinRadius(context.Background(), albany, geography.MilesToMeters(1))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(*queries))
+
+	// The first response was forgotten to make room for the second
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 3, len(*queries))
+
+	_, err = NewUSDAFarmersMarketApi(Options{USDA: NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy)), Geocoder: GeocoderChain{}, USDACache: USDACacheOptions{TTL: time.Hour}})
+	require.NotNil(t, err)
+}
+
+func TestUSDAResponseCacheServesStaleResponses(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(usdaCacheTestResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	usda := NewUSDAClient("key", nil)
+	usda.BaseURL = server.URL
+
+	fmApi, err := NewUSDAFarmersMarketApi(Options{
+		USDA:      usda,
+		Geocoder:  GeocoderChain{},
+		USDACache: USDACacheOptions{Precision: 5, TTL: time.Hour, MaxBytes: 1 << 20, MaxStale: 24 * time.Hour},
+	})
+	require.Nil(t, err)
+
+	fetchedAt := time.Date(2024, time.May, 4, 12, 0, 0, 0, time.UTC)
+	now := fetchedAt
+	fmApi.responses.now = func() time.Time { return now }
+
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+
+	// The expired response is served while the USDA is down
+	failing.Store(true)
+	now = now.Add(2 * time.Hour)
+
+	ctx, report := WithQueryReport(context.Background())
+	records, err := fmApi.NearestN(ctx, -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, fetchedAt, report.DataAsOf())
+	require.Equal(t, []string{"the usda could not be reached, returned the markets it listed at 2024-05-04T12:00:00Z"}, report.Warnings())
+
+	// Until it is too old
+	now = now.Add(24 * time.Hour)
+
+	_, err = fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.NotNil(t, err)
+}
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/jadidbourbaki/gofarm/geography"
 	"github.com/stretchr/testify/require"
 )
 
@@ -162,3 +168,140 @@ func TestParseUSDADatasetMultiRecordDataset(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, len(multiRecordDataset), 2)
 }
+
+func TestUSDARecordFarmersMarketRecord(t *testing.T) {
+	record := USDARecord{
+		ListingName:    "Potsdam Farmers Market",
+		ListingDesc:    "Local produce and crafts",
+		ListingImage:   "listing-312052.jpg",
+		ContactName:    "Jane Doe",
+		ContactEmail:   "jane@example.com",
+		MediaFacebook:  "https://facebook.com/potsdam",
+		MediaInstagram: "https://instagram.com/potsdam",
+		LocationX:      "-73.98557883420489",
+		LocationY:      "40.7805342225485",
+		UpdateTime:     "Mar 27th, 2023",
+	}
+
+	converted, err := record.FarmersMarketRecord()
+	require.Nil(t, err)
+	require.Equal(t, "Local produce and crafts", converted.Description)
+	require.Equal(t, &FarmersMarketContact{Name: "Jane Doe", Email: "jane@example.com"}, converted.Contact)
+	require.Equal(t, &FarmersMarketSocialMedia{Facebook: "https://facebook.com/potsdam", Instagram: "https://instagram.com/potsdam"}, converted.SocialMedia)
+	require.Equal(t, USDAListingImageBaseURL+"listing-312052.jpg", converted.ImageURL)
+	require.Equal(t, time.Date(2023, time.March, 27, 0, 0, 0, 0, time.UTC), *converted.LastUpdated)
+
+	// Placeholder images and missing details are left out
+	record = USDARecord{ListingImage: "default-farmersmarket-4-3.jpg", LocationX: "-73.98", LocationY: "40.78", UpdateTime: ""}
+
+	converted, err = record.FarmersMarketRecord()
+	require.Nil(t, err)
+	require.Empty(t, converted.ImageURL)
+	require.Nil(t, converted.Contact)
+	require.Nil(t, converted.SocialMedia)
+	require.Nil(t, converted.LastUpdated)
+}
+
+func TestParseUSDAUpdateTime(t *testing.T) {
+	for text, expected := range map[string]time.Time{
+		"Mar 27th, 2023": time.Date(2023, time.March, 27, 0, 0, 0, 0, time.UTC),
+		"Jan 1st, 2024":  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"Aug 22nd, 2022": time.Date(2022, time.August, 22, 0, 0, 0, 0, time.UTC),
+		"Oct 3rd, 2021":  time.Date(2021, time.October, 3, 0, 0, 0, 0, time.UTC),
+	} {
+		parsed, err := ParseUSDAUpdateTime(text)
+		require.Nil(t, err, text)
+		require.Equal(t, expected, parsed, text)
+	}
+
+	_, err := ParseUSDAUpdateTime("yesterday")
+	require.NotNil(t, err)
+}
+
+func TestUSDANearestNWidensRadius(t *testing.T) {
+	// Within 100 miles there is one market, within 200 miles two and further out three
+	var radii []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		radius := r.URL.Query().Get("radius")
+		radii = append(radii, radius)
+
+		listings := []string{
+			`{"listing_id": "1", "listing_name": "Near", "location_x": "-73.75", "location_y": "42.65"}`,
+			`{"listing_id": "2", "listing_name": "Further", "location_x": "-73.75", "location_y": "44.65"}`,
+			`{"listing_id": "3", "listing_name": "Furthest", "location_x": "-73.75", "location_y": "46.65"}`,
+		}
+
+		switch radius {
+		case "100":
+			listings = listings[:1]
+		case "200":
+			listings = listings[:2]
+		}
+
+		w.Write([]byte(`{"data": [` + strings.Join(listings, ",") + `]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	usda := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	usda.BaseURL = server.URL
+
+	fmApi, err := NewUSDAFarmersMarketApi(Options{USDA: usda, Geocoder: GeocoderChain{}, USDAMaxMiles: 300})
+	require.Nil(t, err)
+
+	records, err := fmApi.NearestN(context.Background(), 2, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, []string{"100", "200"}, radii)
+
+	// n = -1 only asks for the default radius
+	radii = nil
+	records, err = fmApi.NearestN(context.Background(), -1, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, []string{"100"}, radii)
+
+	// Fewer than n markets within the widest radius is a warning, not an error
+	radii = nil
+	ctx, report := WithQueryReport(context.Background())
+	records, err = fmApi.NearestN(ctx, 5, albany, Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, []string{"100", "200", "300"}, radii)
+	require.Equal(t, []string{"found 3 of the 5 markets requested within 300 miles"}, report.Warnings())
+}
+
+func TestUSDAWithinRadiusAndBoundsClampRadius(t *testing.T) {
+	var radii []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		radii = append(radii, r.URL.Query().Get("radius"))
+		w.Write([]byte(`{"data": [{"listing_id": "1", "listing_name": "Near", "location_x": "-73.75", "location_y": "42.65"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	usda := NewUSDAClient("key", NewUpstreamGuard("usda", USDAUpstreamPolicy))
+	usda.BaseURL = server.URL
+
+	fmApi, err := NewUSDAFarmersMarketApi(Options{USDA: usda, Geocoder: GeocoderChain{}, USDAMaxMiles: 300})
+	require.Nil(t, err)
+
+	// Within the widest radius, the radius is asked for as is
+	ctx, report := WithQueryReport(context.Background())
+	records, err := fmApi.WithinRadius(ctx, albany, geography.MilesToMeters(50))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Empty(t, report.Warnings())
+
+	// A continent is not
+	ctx, report = WithQueryReport(context.Background())
+	records, err = fmApi.WithinRadius(ctx, albany, geography.MilesToMeters(3000))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, []string{"only searched within 300 miles, markets further away were left out"}, report.Warnings())
+
+	ctx, report = WithQueryReport(context.Background())
+	_, err = fmApi.WithinBounds(ctx, geography.HaversinePoint{Latitude: 25, Longitude: -125}, geography.HaversinePoint{Latitude: 49, Longitude: -67}, -1)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(report.Warnings()))
+
+	require.Equal(t, []string{"50", "300", "300"}, radii)
+}
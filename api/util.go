@@ -1,9 +1,40 @@
 package api
 
 import (
+	"fmt"
+
 	"github.com/jadidbourbaki/gofarm/geography"
 )
 
+// ConvertDistances converts the Distance of every record from meters into unit.
+// Records without a known distance are left alone.
+func ConvertDistances(records []FarmersMarketRecord, unit geography.DistanceUnit) {
+	for idx := range records {
+		record := &records[idx]
+
+		if record.Distance < 0 {
+			continue
+		}
+
+		record.Distance = unit.FromMeters(record.Distance)
+		record.DistanceUnits = unit
+	}
+}
+
+// limitRecords returns the first limit records, or all of them if limit is -1
+func limitRecords(records []FarmersMarketRecord, limit int) ([]FarmersMarketRecord, error) {
+	if limit == -1 {
+		return records, nil
+	}
+
+	// The only negative value we accept is -1, which is handled above
+	if limit < 0 {
+		return nil, fmt.Errorf("invalid value for limit")
+	}
+
+	return records[:min(len(records), limit)], nil
+}
+
 // HaversinePoint takes the latitude, longitude of a given record and converts it into a geography.HaversinePoint
 func (record FarmersMarketRecord) HaversinePoint() geography.HaversinePoint {
 	return geography.HaversinePoint{Latitude: record.Location.Latitude, Longitude: record.Location.Longitude}
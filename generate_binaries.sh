@@ -2,4 +2,6 @@
 # This is a cost efficient way of not having to compile on 
 # the server. Currently, we are only paying for 0.5 GB of
 # RAM and very limited compute.
-GOOS=linux GOARCH=amd64 go build -o binaries/lilyfarm
\ No newline at end of file
+# The gazetteer table embedded in the binary is generated first, see api/gazetteer.go
+go generate ./api || exit 1
+GOOS=linux GOARCH=amd64 go build -o binaries/lilyfarm
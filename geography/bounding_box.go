@@ -0,0 +1,96 @@
+package geography
+
+import (
+	"fmt"
+	"math"
+)
+
+// BoundingBox is the area between two parallels and two meridians, such as the
+// viewport of a map. A box whose western edge is east of its eastern edge crosses
+// the antimeridian, e.g. the Aleutian Islands from 172°E to 172°W are the box from
+// (51, 172) to (55, -172).
+type BoundingBox struct {
+	SouthWest HaversinePoint
+	NorthEast HaversinePoint
+}
+
+// NewBoundingBox returns the box with the given south-west and north-east corners
+func NewBoundingBox(southWest HaversinePoint, northEast HaversinePoint) (BoundingBox, error) {
+	for _, corner := range []HaversinePoint{southWest, northEast} {
+		if !(corner.Latitude >= -90 && corner.Latitude <= 90) {
+			return BoundingBox{}, fmt.Errorf("invalid latitude: %v", corner.Latitude)
+		}
+
+		if !(corner.Longitude >= -180 && corner.Longitude <= 180) {
+			return BoundingBox{}, fmt.Errorf("invalid longitude: %v", corner.Longitude)
+		}
+	}
+
+	if southWest.Latitude > northEast.Latitude {
+		return BoundingBox{}, fmt.Errorf("south-west corner is north of the north-east corner")
+	}
+
+	return BoundingBox{SouthWest: southWest, NorthEast: northEast}, nil
+}
+
+// CrossesAntimeridian returns true if the box spans the 180th meridian
+func (box BoundingBox) CrossesAntimeridian() bool {
+	return box.SouthWest.Longitude > box.NorthEast.Longitude
+}
+
+// longitudeSpan returns the width of the box in degrees of longitude
+func (box BoundingBox) longitudeSpan() float64 {
+	span := box.NorthEast.Longitude - box.SouthWest.Longitude
+	if box.CrossesAntimeridian() {
+		span += 360
+	}
+
+	return span
+}
+
+// Contains returns true if point is inside the box or on its edges
+func (box BoundingBox) Contains(point HaversinePoint) bool {
+	if point.Latitude < box.SouthWest.Latitude || point.Latitude > box.NorthEast.Latitude {
+		return false
+	}
+
+	if box.CrossesAntimeridian() {
+		return point.Longitude >= box.SouthWest.Longitude || point.Longitude <= box.NorthEast.Longitude
+	}
+
+	return point.Longitude >= box.SouthWest.Longitude && point.Longitude <= box.NorthEast.Longitude
+}
+
+// Center returns the point halfway between the edges of the box
+func (box BoundingBox) Center() HaversinePoint {
+	longitude := box.SouthWest.Longitude + box.longitudeSpan()/2
+	if longitude > 180 {
+		longitude -= 360
+	}
+
+	return HaversinePoint{
+		Latitude:  (box.SouthWest.Latitude + box.NorthEast.Latitude) / 2,
+		Longitude: longitude,
+	}
+}
+
+// Radius returns the distance (in meters) from the Center of the box to the furthest
+// point in it, so that the box lies within that distance of its Center. The furthest
+// point is one of the corners, as long as the box is at most half way around the
+// Earth; otherwise the box is treated as if it could cover every point on Earth.
+func (box BoundingBox) Radius() float64 {
+	center := box.Center()
+
+	if box.longitudeSpan() > 180 {
+		return haversineDistance(0, 0, 0, 180)
+	}
+
+	radius := 0.0
+	for _, latitude := range []float64{box.SouthWest.Latitude, box.NorthEast.Latitude} {
+		for _, longitude := range []float64{box.SouthWest.Longitude, box.NorthEast.Longitude} {
+			radius = math.Max(radius, haversineDistance(center.Latitude, center.Longitude, latitude, longitude))
+		}
+	}
+
+	return radius
+}
@@ -0,0 +1,85 @@
+package geography
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var aleutians = BoundingBox{
+	SouthWest: HaversinePoint{Latitude: 51, Longitude: 172},
+	NorthEast: HaversinePoint{Latitude: 55, Longitude: -172},
+}
+
+func TestBoundingBoxContains(t *testing.T) {
+	manhattan, err := NewBoundingBox(HaversinePoint{40.70, -74.02}, HaversinePoint{40.88, -73.91})
+	require.Nil(t, err)
+	require.False(t, manhattan.CrossesAntimeridian())
+
+	require.True(t, manhattan.Contains(HaversinePoint{40.77394, -73.9506}))
+	require.False(t, manhattan.Contains(HaversinePoint{42.64819, -73.75383}))
+
+	require.True(t, aleutians.CrossesAntimeridian())
+
+	// Attu Island, Adak and the antimeridian itself
+	require.True(t, aleutians.Contains(HaversinePoint{52.9, 173.1}))
+	require.True(t, aleutians.Contains(HaversinePoint{51.88, -176.66}))
+	require.True(t, aleutians.Contains(HaversinePoint{53, 180}))
+	require.True(t, aleutians.Contains(HaversinePoint{53, -180}))
+
+	// Same latitudes, but on the other side of the world
+	require.False(t, aleutians.Contains(HaversinePoint{53, 0}))
+	require.False(t, aleutians.Contains(HaversinePoint{53, 171}))
+	require.False(t, aleutians.Contains(HaversinePoint{56, 179}))
+}
+
+func TestBoundingBoxCenter(t *testing.T) {
+	require.Equal(t, HaversinePoint{Latitude: 53, Longitude: 180}, aleutians.Center())
+
+	box := BoundingBox{SouthWest: HaversinePoint{0, 170}, NorthEast: HaversinePoint{10, -150}}
+	require.Equal(t, HaversinePoint{Latitude: 5, Longitude: -170}, box.Center())
+}
+
+func TestBoundingBoxRadius(t *testing.T) {
+	random := rand.New(rand.NewPCG(7, 8))
+
+	for _, box := range []BoundingBox{
+		aleutians,
+		{SouthWest: HaversinePoint{40.70, -74.02}, NorthEast: HaversinePoint{40.88, -73.91}},
+		{SouthWest: HaversinePoint{24, -125}, NorthEast: HaversinePoint{50, -66}},
+		{SouthWest: HaversinePoint{60, -170}, NorthEast: HaversinePoint{90, 0}},
+	} {
+		center := box.Center()
+		radius := box.Radius()
+
+		// Every point in the box is within the radius of its center
+		for i := 0; i < 1000; i++ {
+			point := HaversinePoint{
+				Latitude:  box.SouthWest.Latitude + random.Float64()*(box.NorthEast.Latitude-box.SouthWest.Latitude),
+				Longitude: box.SouthWest.Longitude + random.Float64()*box.longitudeSpan(),
+			}
+
+			if point.Longitude > 180 {
+				point.Longitude -= 360
+			}
+
+			require.True(t, box.Contains(point))
+
+			distance, err := DefaultHaversineMetricSpace.Distance(center, point)
+			require.Nil(t, err)
+			require.LessOrEqual(t, distance, radius)
+		}
+	}
+}
+
+func TestNewBoundingBoxValidates(t *testing.T) {
+	_, err := NewBoundingBox(HaversinePoint{41, -74}, HaversinePoint{40, -73})
+	require.NotNil(t, err)
+
+	_, err = NewBoundingBox(HaversinePoint{40, -74}, HaversinePoint{91, -73})
+	require.NotNil(t, err)
+
+	_, err = NewBoundingBox(HaversinePoint{40, -181}, HaversinePoint{41, -73})
+	require.NotNil(t, err)
+}
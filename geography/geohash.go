@@ -0,0 +1,81 @@
+package geography
+
+import (
+	"fmt"
+	"strings"
+)
+
+// geohashAlphabet is the base 32 alphabet of geohashes, each character encoding five bits
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash returns the geohash of point with the given number of characters. The
+// geohash names the cell of a grid that point is in; every character added splits
+// the cell into 32 smaller ones, e.g. cells of 5 characters are about 5 km wide.
+func Geohash(point HaversinePoint, precision int) string {
+	latitude := [2]float64{-90, 90}
+	longitude := [2]float64{-180, 180}
+
+	var hash strings.Builder
+
+	// Bits alternate between longitude and latitude, starting with longitude
+	even := true
+	for hash.Len() < precision {
+		character := 0
+		for bit := 0; bit < 5; bit++ {
+			interval, value := &latitude, point.Latitude
+			if even {
+				interval, value = &longitude, point.Longitude
+			}
+
+			middle := (interval[0] + interval[1]) / 2
+			character <<= 1
+			if value >= middle {
+				character |= 1
+				interval[0] = middle
+			} else {
+				interval[1] = middle
+			}
+
+			even = !even
+		}
+
+		hash.WriteByte(geohashAlphabet[character])
+	}
+
+	return hash.String()
+}
+
+// GeohashBounds returns the cell a geohash names
+func GeohashBounds(hash string) (BoundingBox, error) {
+	latitude := [2]float64{-90, 90}
+	longitude := [2]float64{-180, 180}
+
+	even := true
+	for _, character := range strings.ToLower(hash) {
+		value := strings.IndexRune(geohashAlphabet, character)
+		if value < 0 {
+			return BoundingBox{}, fmt.Errorf("invalid geohash: %q", hash)
+		}
+
+		for bit := 4; bit >= 0; bit-- {
+			interval := &latitude
+			if even {
+				interval = &longitude
+			}
+
+			middle := (interval[0] + interval[1]) / 2
+			if value&(1<<bit) != 0 {
+				interval[0] = middle
+			} else {
+				interval[1] = middle
+			}
+
+			even = !even
+		}
+	}
+
+	return BoundingBox{
+		SouthWest: HaversinePoint{Latitude: latitude[0], Longitude: longitude[0]},
+		NorthEast: HaversinePoint{Latitude: latitude[1], Longitude: longitude[1]},
+	}, nil
+}
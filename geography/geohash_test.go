@@ -0,0 +1,34 @@
+package geography
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeohash(t *testing.T) {
+	require.Equal(t, "u4pruydqqvj", Geohash(HaversinePoint{Latitude: 57.64911, Longitude: 10.40744}, 11))
+	require.Equal(t, "dr5re", Geohash(HaversinePoint{Latitude: 40.7128, Longitude: -74.0060}, 5))
+	require.Equal(t, "", Geohash(HaversinePoint{Latitude: 40.7128, Longitude: -74.0060}, 0))
+}
+
+func TestGeohashBounds(t *testing.T) {
+	point := HaversinePoint{Latitude: 57.64911, Longitude: 10.40744}
+
+	for precision := 1; precision <= 9; precision++ {
+		box, err := GeohashBounds(Geohash(point, precision))
+		require.Nil(t, err)
+		require.True(t, box.Contains(point))
+
+		// The center of the cell has the same geohash
+		require.Equal(t, Geohash(point, precision), Geohash(box.Center(), precision))
+	}
+
+	// Cells of 5 characters are about 5 km wide
+	box, err := GeohashBounds("dr5ru")
+	require.Nil(t, err)
+	require.InDelta(t, 3500, box.Radius(), 500)
+
+	_, err = GeohashBounds("dr5ra")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,29 @@
+package geography
+
+// Polygon is an area bounded by the straight lines, on a map with latitude and
+// longitude as its axes, between consecutive vertices and between the last and the
+// first vertex. It is meant for the outlines of areas such as states, and must not
+// cross the antimeridian.
+type Polygon []HaversinePoint
+
+// Contains returns true if p is inside the polygon. Points on its boundary may
+// be either inside or outside.
+func (polygon Polygon) Contains(p HaversinePoint) bool {
+	inside := false
+
+	// Count the edges crossed by a ray going east from p
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+
+		if (a.Latitude > p.Latitude) == (b.Latitude > p.Latitude) {
+			continue
+		}
+
+		crossing := a.Longitude + (p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)*(b.Longitude-a.Longitude)
+		if p.Longitude < crossing {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
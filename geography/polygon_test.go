@@ -0,0 +1,21 @@
+package geography
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolygonContains(t *testing.T) {
+	// An L shape around the corner at (1, 1)
+	polygon := Polygon{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}
+
+	require.True(t, polygon.Contains(HaversinePoint{0.5, 0.5}))
+	require.True(t, polygon.Contains(HaversinePoint{0.5, 1.5}))
+	require.True(t, polygon.Contains(HaversinePoint{1.5, 0.5}))
+	require.False(t, polygon.Contains(HaversinePoint{1.5, 1.5}))
+	require.False(t, polygon.Contains(HaversinePoint{-1, 0.5}))
+	require.False(t, polygon.Contains(HaversinePoint{0.5, 3}))
+
+	require.False(t, Polygon{}.Contains(HaversinePoint{0, 0}))
+}
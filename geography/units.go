@@ -0,0 +1,47 @@
+package geography
+
+import "fmt"
+
+// DistanceUnit is a unit of length that distances can be reported in.
+// Distances are computed in meters, see HaversineMetricSpace.
+type DistanceUnit string
+
+const (
+	Meters     DistanceUnit = "m"
+	Kilometers DistanceUnit = "km"
+	Miles      DistanceUnit = "mi"
+)
+
+// ParseDistanceUnit returns the DistanceUnit for its abbreviation (m, km or mi)
+func ParseDistanceUnit(unit string) (DistanceUnit, error) {
+	switch DistanceUnit(unit) {
+	case Meters, Kilometers, Miles:
+		return DistanceUnit(unit), nil
+	}
+
+	return "", fmt.Errorf("unknown distance unit: %q", unit)
+}
+
+// FromMeters converts a length in meters into this unit
+func (unit DistanceUnit) FromMeters(meters float64) float64 {
+	switch unit {
+	case Kilometers:
+		return MetersToKilometers(meters)
+	case Miles:
+		return MetersToMiles(meters)
+	}
+
+	return meters
+}
+
+// ToMeters converts a length in this unit into meters
+func (unit DistanceUnit) ToMeters(length float64) float64 {
+	switch unit {
+	case Kilometers:
+		return KilometersToMeters(length)
+	case Miles:
+		return MilesToMeters(length)
+	}
+
+	return length
+}
@@ -0,0 +1,28 @@
+package geography
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDistanceUnit(t *testing.T) {
+	for _, abbreviation := range []string{"m", "km", "mi"} {
+		unit, err := ParseDistanceUnit(abbreviation)
+		require.Nil(t, err)
+		require.Equal(t, abbreviation, string(unit))
+	}
+
+	_, err := ParseDistanceUnit("furlongs")
+	require.NotNil(t, err)
+}
+
+func TestDistanceUnitConversions(t *testing.T) {
+	require.Equal(t, 1500.0, Meters.FromMeters(1500))
+	require.Equal(t, 1.5, Kilometers.FromMeters(1500))
+	require.InDelta(t, 1.0, Miles.FromMeters(1609.344), 1e-5)
+
+	for _, unit := range []DistanceUnit{Meters, Kilometers, Miles} {
+		require.InDelta(t, 1234.5, unit.FromMeters(unit.ToMeters(1234.5)), 1e-9)
+	}
+}
@@ -12,3 +12,18 @@ func MetersToMiles(meters float64) float64 {
 func MetersToKilometersInteger(meters float64) int64 {
 	return int64(meters / 1000.0)
 }
+
+// MetersToKilometers converts length in meters to length in kilometers
+func MetersToKilometers(meters float64) float64 {
+	return meters / 1000.0
+}
+
+// KilometersToMeters converts length in kilometers to length in meters
+func KilometersToMeters(kilometers float64) float64 {
+	return kilometers * 1000.0
+}
+
+// MilesToMeters converts length in miles to length in meters
+func MilesToMeters(miles float64) float64 {
+	return miles / 0.000621371
+}
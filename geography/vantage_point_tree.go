@@ -0,0 +1,288 @@
+package geography
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Neighbor is a point found by a query on a VantagePointTree
+type Neighbor struct {
+	Index    int     // index of the point in the slice the tree was built from
+	Distance float64 // distance from the queried point, in the units of the MetricSpace
+}
+
+// vantagePointNode is a node of a VantagePointTree. Every point in the inside
+// subtree is at most threshold away from the vantage point, and every point in
+// the outside subtree at least threshold away.
+type vantagePointNode struct {
+	index     int // index of the vantage point
+	threshold float64
+	inside    int // index of the inside subtree in nodes, -1 if empty
+	outside   int // index of the outside subtree in nodes, -1 if empty
+}
+
+// VantagePointTree is a spatial index answering k-nearest and radius queries over
+// a fixed set of points [1]. Unlike a k-d tree, it only relies on the distance
+// function of the MetricSpace satisfying the triangle inequality, so it works for
+// the HaversineMetricSpace without projecting the points. Queries visit O(log n)
+// nodes on average. A VantagePointTree is immutable and safe for concurrent use.
+//
+// [1]: https://en.wikipedia.org/wiki/Vantage-point_tree
+type VantagePointTree struct {
+	metricSpace MetricSpace
+	points      []Point
+	nodes       []vantagePointNode
+	root        int // -1 if the tree is empty
+}
+
+// vantagePointCandidate is a point that has not been placed in the tree yet
+type vantagePointCandidate struct {
+	index    int
+	distance float64 // distance from the vantage point of the subtree being built
+}
+
+// NewVantagePointTree builds a VantagePointTree over points in O(n log² n) distance
+// computations. The tree keeps a reference to points, which must not be modified.
+func NewVantagePointTree(metricSpace MetricSpace, points []Point) (*VantagePointTree, error) {
+	tree := &VantagePointTree{
+		metricSpace: metricSpace,
+		points:      points,
+		nodes:       make([]vantagePointNode, 0, len(points)),
+	}
+
+	candidates := make([]vantagePointCandidate, len(points))
+	for idx := range candidates {
+		candidates[idx].index = idx
+	}
+
+	var err error
+	tree.root, err = tree.build(candidates)
+	if err != nil {
+		return nil, fmt.Errorf("building vantage point tree: %w", err)
+	}
+
+	return tree, nil
+}
+
+// build adds a subtree over candidates to the tree and returns the index of its root
+func (tree *VantagePointTree) build(candidates []vantagePointCandidate) (int, error) {
+	if len(candidates) == 0 {
+		return -1, nil
+	}
+
+	// Candidates below the root are sorted by their distance from the vantage point
+	// of the parent, so the last one is the farthest from it. Points at the edge of a
+	// set make better vantage points than points in its middle, whose spheres cut
+	// through more of the set, so the last candidate becomes the vantage point.
+	last := len(candidates) - 1
+	candidates[0], candidates[last] = candidates[last], candidates[0]
+
+	vantagePoint := tree.points[candidates[0].index]
+	rest := candidates[1:]
+
+	for idx := range rest {
+		distance, err := tree.metricSpace.Distance(vantagePoint, tree.points[rest[idx].index])
+		if err != nil {
+			return -1, err
+		}
+
+		rest[idx].distance = distance
+	}
+
+	// Split the rest at the median distance
+	slices.SortFunc(rest, func(a, b vantagePointCandidate) int {
+		return cmp.Compare(a.distance, b.distance)
+	})
+
+	median := len(rest) / 2
+
+	nodeIndex := len(tree.nodes)
+	tree.nodes = append(tree.nodes, vantagePointNode{index: candidates[0].index, inside: -1, outside: -1})
+
+	if len(rest) == 0 {
+		return nodeIndex, nil
+	}
+
+	threshold := rest[median].distance
+
+	inside, err := tree.build(rest[:median])
+	if err != nil {
+		return -1, err
+	}
+
+	outside, err := tree.build(rest[median:])
+	if err != nil {
+		return -1, err
+	}
+
+	tree.nodes[nodeIndex].threshold = threshold
+	tree.nodes[nodeIndex].inside = inside
+	tree.nodes[nodeIndex].outside = outside
+
+	return nodeIndex, nil
+}
+
+// Len returns the number of points in the tree
+func (tree *VantagePointTree) Len() int {
+	return len(tree.points)
+}
+
+// neighborHeap is a max-heap of the nearest neighbors found so far
+type neighborHeap []Neighbor
+
+func (h neighborHeap) Len() int { return len(h) }
+func (h neighborHeap) Less(i, j int) bool {
+	return compareNeighbors(h[i], h[j]) > 0
+}
+func (h neighborHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(x interface{}) { *h = append(*h, x.(Neighbor)) }
+func (h *neighborHeap) Pop() interface{} {
+	old := *h
+	neighbor := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return neighbor
+}
+
+// compareNeighbors orders neighbors by distance, and points at the same distance
+// by their index so that query results are deterministic
+func compareNeighbors(a, b Neighbor) int {
+	if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.Index, b.Index)
+}
+
+// Nearest returns the k points nearest to query in ascending order of distance,
+// or every point if there are fewer than k
+func (tree *VantagePointTree) Nearest(query Point, k int) ([]Neighbor, error) {
+	return tree.NearestMatching(query, k, nil)
+}
+
+// NearestMatching returns the k points nearest to query for which match returns true,
+// in ascending order of distance, or every matching point if there are fewer than k.
+// match is called with the index of a point in the slice the tree was built from.
+// A nil match matches every point.
+func (tree *VantagePointTree) NearestMatching(query Point, k int, match func(index int) bool) ([]Neighbor, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("invalid value for k: %v", k)
+	}
+
+	nearest := make(neighborHeap, 0, min(k, tree.Len()))
+	if k == 0 {
+		return nearest, nil
+	}
+
+	// tau is the distance of the k-th nearest point found so far
+	tau := math.Inf(1)
+
+	var search func(nodeIndex int) error
+	search = func(nodeIndex int) error {
+		if nodeIndex < 0 {
+			return nil
+		}
+
+		node := tree.nodes[nodeIndex]
+
+		distance, err := tree.metricSpace.Distance(query, tree.points[node.index])
+		if err != nil {
+			return err
+		}
+
+		// A point that does not match is still searched through, its subtrees may match
+		if match == nil || match(node.index) {
+			neighbor := Neighbor{Index: node.index, Distance: distance}
+			if len(nearest) < k {
+				heap.Push(&nearest, neighbor)
+			} else if compareNeighbors(neighbor, nearest[0]) < 0 {
+				nearest[0] = neighbor
+				heap.Fix(&nearest, 0)
+			}
+		}
+
+		if len(nearest) == k {
+			tau = nearest[0].Distance
+		}
+
+		// By the triangle inequality, a point inside the threshold is at least
+		// distance - threshold away from the query, and a point outside it at least
+		// threshold - distance. Search the side the query is on first, it is the one
+		// most likely to shrink tau.
+		if distance < node.threshold {
+			if err := search(node.inside); err != nil {
+				return err
+			}
+
+			if node.threshold-distance <= tau {
+				return search(node.outside)
+			}
+
+			return nil
+		}
+
+		if err := search(node.outside); err != nil {
+			return err
+		}
+
+		if distance-node.threshold <= tau {
+			return search(node.inside)
+		}
+
+		return nil
+	}
+
+	if err := search(tree.root); err != nil {
+		return nil, fmt.Errorf("nearest: %w", err)
+	}
+
+	slices.SortFunc(nearest, compareNeighbors)
+
+	return nearest, nil
+}
+
+// WithinRadius returns every point at most radius away from query in ascending
+// order of distance
+func (tree *VantagePointTree) WithinRadius(query Point, radius float64) ([]Neighbor, error) {
+	var neighbors []Neighbor
+
+	var search func(nodeIndex int) error
+	search = func(nodeIndex int) error {
+		if nodeIndex < 0 {
+			return nil
+		}
+
+		node := tree.nodes[nodeIndex]
+
+		distance, err := tree.metricSpace.Distance(query, tree.points[node.index])
+		if err != nil {
+			return err
+		}
+
+		if distance <= radius {
+			neighbors = append(neighbors, Neighbor{Index: node.index, Distance: distance})
+		}
+
+		if distance-node.threshold <= radius {
+			if err := search(node.inside); err != nil {
+				return err
+			}
+		}
+
+		if node.threshold-distance <= radius {
+			return search(node.outside)
+		}
+
+		return nil
+	}
+
+	if err := search(tree.root); err != nil {
+		return nil, fmt.Errorf("within radius: %w", err)
+	}
+
+	slices.SortFunc(neighbors, compareNeighbors)
+
+	return neighbors, nil
+}
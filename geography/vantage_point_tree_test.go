@@ -0,0 +1,184 @@
+package geography
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// randomPoints returns n points scattered over the whole globe, including
+// around the poles and the antimeridian
+func randomPoints(random *rand.Rand, n int) []Point {
+	points := make([]Point, n)
+	for idx := range points {
+		points[idx] = HaversinePoint{
+			Latitude:  random.Float64()*180 - 90,
+			Longitude: random.Float64()*360 - 180,
+		}
+	}
+
+	return points
+}
+
+// bruteForceNeighbors computes the distance from query to every point and sorts them
+func bruteForceNeighbors(t testing.TB, points []Point, query Point) []Neighbor {
+	neighbors := make([]Neighbor, len(points))
+	for idx, point := range points {
+		distance, err := DefaultHaversineMetricSpace.Distance(query, point)
+		require.Nil(t, err)
+
+		neighbors[idx] = Neighbor{Index: idx, Distance: distance}
+	}
+
+	slices.SortFunc(neighbors, compareNeighbors)
+
+	return neighbors
+}
+
+func TestVantagePointTreeNearest(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	points := randomPoints(random, 2000)
+
+	tree, err := NewVantagePointTree(DefaultHaversineMetricSpace, points)
+	require.Nil(t, err)
+	require.Equal(t, 2000, tree.Len())
+
+	for _, query := range randomPoints(random, 50) {
+		expected := bruteForceNeighbors(t, points, query)
+
+		for _, k := range []int{0, 1, 7, 100} {
+			neighbors, err := tree.Nearest(query, k)
+			require.Nil(t, err)
+			require.Equal(t, expected[:k], neighbors)
+		}
+	}
+
+	// Asking for more points than there are returns all of them
+	neighbors, err := tree.Nearest(newYork, 5000)
+	require.Nil(t, err)
+	require.Equal(t, bruteForceNeighbors(t, points, newYork), neighbors)
+
+	_, err = tree.Nearest(newYork, -1)
+	require.NotNil(t, err)
+}
+
+func TestVantagePointTreeNearestMatching(t *testing.T) {
+	random := rand.New(rand.NewPCG(9, 10))
+	points := randomPoints(random, 2000)
+
+	tree, err := NewVantagePointTree(DefaultHaversineMetricSpace, points)
+	require.Nil(t, err)
+
+	// Only every seventh point matches
+	match := func(index int) bool { return index%7 == 0 }
+
+	for _, query := range randomPoints(random, 50) {
+		var expected []Neighbor
+		for _, neighbor := range bruteForceNeighbors(t, points, query) {
+			if match(neighbor.Index) {
+				expected = append(expected, neighbor)
+			}
+		}
+
+		neighbors, err := tree.NearestMatching(query, 10, match)
+		require.Nil(t, err)
+		require.Equal(t, expected[:10], neighbors)
+	}
+}
+
+func TestVantagePointTreeWithinRadius(t *testing.T) {
+	random := rand.New(rand.NewPCG(3, 4))
+	points := randomPoints(random, 2000)
+
+	tree, err := NewVantagePointTree(DefaultHaversineMetricSpace, points)
+	require.Nil(t, err)
+
+	for _, query := range randomPoints(random, 50) {
+		expected := bruteForceNeighbors(t, points, query)
+
+		for _, radius := range []float64{0, 100000, 1000000, 25000000} {
+			neighbors, err := tree.WithinRadius(query, radius)
+			require.Nil(t, err)
+
+			var expectedWithinRadius []Neighbor
+			for _, neighbor := range expected {
+				if neighbor.Distance <= radius {
+					expectedWithinRadius = append(expectedWithinRadius, neighbor)
+				}
+			}
+
+			require.Equal(t, expectedWithinRadius, neighbors)
+		}
+	}
+}
+
+func TestVantagePointTreeEmptyAndDuplicates(t *testing.T) {
+	tree, err := NewVantagePointTree(DefaultHaversineMetricSpace, nil)
+	require.Nil(t, err)
+
+	neighbors, err := tree.Nearest(newYork, 3)
+	require.Nil(t, err)
+	require.Empty(t, neighbors)
+
+	// Points at the same distance come back in the order they were given in
+	points := []Point{sanDiego, elPaso, sanDiego, sanDiego}
+	tree, err = NewVantagePointTree(DefaultHaversineMetricSpace, points)
+	require.Nil(t, err)
+
+	neighbors, err = tree.Nearest(sanDiego, 3)
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 2, 3}, []int{neighbors[0].Index, neighbors[1].Index, neighbors[2].Index})
+}
+
+// The New York State dataset has around 500 markets, the benchmarks also cover
+// datasets the size of the national USDA directory
+var benchmarkSizes = []int{500, 10000}
+
+func BenchmarkNearestSort(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			points := randomPoints(rand.New(rand.NewPCG(5, 6)), size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bruteForceNeighbors(b, points, newYork)
+			}
+		})
+	}
+}
+
+func BenchmarkNearestVantagePointTree(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			points := randomPoints(rand.New(rand.NewPCG(5, 6)), size)
+
+			tree, err := NewVantagePointTree(DefaultHaversineMetricSpace, points)
+			require.Nil(b, err)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := tree.Nearest(newYork, 10); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBuildVantagePointTree(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			points := randomPoints(rand.New(rand.NewPCG(5, 6)), size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewVantagePointTree(DefaultHaversineMetricSpace, points); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
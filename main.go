@@ -2,23 +2,167 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/jadidbourbaki/gofarm/api"
 	"github.com/jadidbourbaki/gofarm/service"
 )
 
 // port is the port the service runs on
 var port int
 
+// config is the configuration the service runs with
+var config = service.DefaultConfig()
+
+// fileDatasources is the path of the JSON file describing the datasources loaded
+// from local files, see api.ReadFileDatasourceOptions
+var fileDatasources string
+
+// socrataDatasources is the path of the JSON file describing the datasources
+// downloaded from Socrata portals, see api.ReadSocrataDatasourceOptions
+var socrataDatasources string
+
+// durationMapFlag is a flag of the form "key=duration,key=duration"
+type durationMapFlag map[string]time.Duration
+
+func (f durationMapFlag) String() string {
+	var pairs []string
+	for key, duration := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, duration))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (f durationMapFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		key, durationString, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected key=duration, got %q", pair)
+		}
+
+		duration, err := time.ParseDuration(durationString)
+		if err != nil {
+			return err
+		}
+
+		f[strings.TrimSpace(key)] = duration
+	}
+
+	return nil
+}
+
+// stringListFlag is a flag of the form "value,value"
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = nil
+	for _, item := range strings.Split(value, ",") {
+		*f = append(*f, strings.TrimSpace(item))
+	}
+
+	return nil
+}
+
 func parseFlags() {
 	flag.IntVar(&port, "p", 443, "port to run service on")
+	flag.Var((*stringListFlag)(&config.Datasources), "datasources",
+		"datasources to serve, every one if empty, e.g. newyork,usda,all")
+	flag.StringVar(&fileDatasources, "file-datasources", "",
+		"JSON file describing datasources loaded from local JSON, NDJSON or CSV files")
+	flag.StringVar(&socrataDatasources, "socrata-datasources", "",
+		"JSON file describing datasources downloaded from Socrata open data portals")
+	flag.DurationVar(&config.UpstreamTimeout, "upstream-timeout", config.UpstreamTimeout,
+		"deadline for upstream requests made while serving a single request")
+	flag.Var(durationMapFlag(config.UpstreamTimeouts), "upstream-timeouts",
+		"per-datasource upstream deadlines overriding -upstream-timeout, e.g. usda=5s,newyork=30s")
+	flag.Var((*stringListFlag)(&config.Geocoders), "geocoders",
+		"order to resolve zip codes in, any of gazetteer and geonames, e.g. gazetteer,geonames")
+	flag.StringVar(&config.ZipCodeCache.Path, "zip-cache-path", config.ZipCodeCache.Path,
+		"file to persist the zip code cache to across restarts, not persisted if empty")
+	flag.IntVar(&config.ZipCodeCache.Capacity, "zip-cache-size", config.ZipCodeCache.Capacity,
+		"maximum number of zip codes kept in the in-memory cache")
+	flag.DurationVar(&config.ZipCodeCache.TTL, "zip-cache-ttl", config.ZipCodeCache.TTL,
+		"how long a zip code location is cached for")
+	flag.DurationVar(&config.RefreshInterval, "refresh-interval", config.RefreshInterval,
+		"how often downloaded datasources are refreshed in the background, never if 0")
+	flag.Float64Var(&config.RefreshJitter, "refresh-jitter", config.RefreshJitter,
+		"fraction by which background refreshes are randomly spread")
+	flag.DurationVar(&config.RefreshMaxBackoff, "refresh-max-backoff", config.RefreshMaxBackoff,
+		"maximum delay before retrying a failed background refresh")
+	flag.IntVar(&config.WithinBoundsLimit, "within-bounds-limit", config.WithinBoundsLimit,
+		"maximum number of markets returned by a bounding box query")
+	flag.IntVar(&config.USDAMaxMiles, "usda-max-radius", config.USDAMaxMiles,
+		"widest radius, in miles, searched in the USDA datasource")
+	flag.DurationVar(&config.USDACache.TTL, "usda-cache-ttl", config.USDACache.TTL,
+		"how long responses of the USDA API are cached for, not cached if zero")
+	flag.IntVar(&config.USDACache.MaxBytes, "usda-cache-bytes", config.USDACache.MaxBytes,
+		"maximum size of the cached responses of the USDA API")
+	flag.DurationVar(&config.USDACache.MaxStale, "usda-cache-max-stale", config.USDACache.MaxStale,
+		"how long expired responses of the USDA API are served for while it cannot be reached")
 	flag.Parse()
 
 }
 
+// registerFileDatasources registers the datasources described in fileDatasources
+func registerFileDatasources() error {
+	if fileDatasources == "" {
+		return nil
+	}
+
+	fileOptions, err := api.ReadFileDatasourceOptions(fileDatasources)
+	if err != nil {
+		return err
+	}
+
+	for _, options := range fileOptions {
+		if err := api.RegisterFileDatasource(options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registerSocrataDatasources registers the datasources described in socrataDatasources
+func registerSocrataDatasources() error {
+	if socrataDatasources == "" {
+		return nil
+	}
+
+	socrataOptions, err := api.ReadSocrataDatasourceOptions(socrataDatasources)
+	if err != nil {
+		return err
+	}
+
+	for _, options := range socrataOptions {
+		if err := api.RegisterSocrataDatasource(options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func main() {
 	parseFlags()
 
-	service := service.New()
+	if err := registerFileDatasources(); err != nil {
+		log.Fatalf("could not load file datasources: %v", err)
+	}
+
+	if err := registerSocrataDatasources(); err != nil {
+		log.Fatalf("could not load socrata datasources: %v", err)
+	}
+
+	service := service.New(config)
 	defer service.Shutdown()
 	service.Run(port, true)
 }
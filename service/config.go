@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/api"
+)
+
+// Config contains the tunable parameters of the service
+type Config struct {
+	// UpstreamTimeout is the deadline for the upstream requests made while serving
+	// a single request to a datasource without an entry in UpstreamTimeouts
+	UpstreamTimeout time.Duration
+
+	// UpstreamTimeouts maps a datasource name to the deadline for the upstream
+	// requests made while serving a single request to that datasource
+	UpstreamTimeouts map[string]time.Duration
+
+	// Datasources are the names of the datasources served, every registered
+	// datasource if empty
+	Datasources []string
+
+	// ApiOptions contains the upstream clients the datasources are constructed
+	// with. If nil, clients for the real upstream APIs are created from the
+	// credentials in the environment.
+	ApiOptions *api.Options
+
+	// Geocoders is the order zip codes are resolved in, see api.NewGeocoderChain.
+	// It is only used when ApiOptions is nil.
+	Geocoders []string
+
+	// ZipCodeCache configures the cache in front of the Geocoders.
+	// It is only used when ApiOptions is nil.
+	ZipCodeCache api.GeocoderCacheOptions
+
+	// ZipCodeCacheStatsInterval is how often the counters of the zip code cache
+	// are logged, never if zero
+	ZipCodeCacheStatsInterval time.Duration
+
+	// RefreshInterval is how often datasources that are downloaded in their
+	// entirety (i.e. not Live) are refreshed in the background, never if zero
+	RefreshInterval time.Duration
+
+	// RefreshJitter randomly spreads every refresh delay by up to this fraction
+	// of it in either direction
+	RefreshJitter float64
+
+	// RefreshMinBackoff is the delay before retrying a failed refresh. It doubles
+	// with every consecutive failure up to RefreshMaxBackoff.
+	RefreshMinBackoff time.Duration
+	RefreshMaxBackoff time.Duration
+
+	// WithinBoundsLimit is the most markets a bounding box query returns
+	WithinBoundsLimit int
+
+	// USDAMaxMiles is the widest radius the USDA datasource searches, see
+	// api.Options.USDAMaxMiles. It is only used when
+	// ApiOptions is nil.
+	USDAMaxMiles int
+
+	// USDACache configures the cache of the responses of the USDA API.
+	// It is only used when ApiOptions is nil.
+	USDACache api.USDACacheOptions
+}
+
+// DefaultConfig returns the configuration the service runs with unless told otherwise
+func DefaultConfig() Config {
+	return Config{
+		Geocoders:                 api.DefaultGeocoderOrder,
+		ZipCodeCache:              api.DefaultGeocoderCacheOptions(),
+		ZipCodeCacheStatsInterval: time.Hour,
+		RefreshInterval:           6 * time.Hour,
+		RefreshJitter:             0.1,
+		RefreshMinBackoff:         time.Minute,
+		RefreshMaxBackoff:         time.Hour,
+		WithinBoundsLimit:         500,
+		USDAMaxMiles:              api.DefaultUSDAMaxMiles,
+		USDACache:                 api.DefaultUSDACacheOptions(),
+		UpstreamTimeout:           10 * time.Second,
+		UpstreamTimeouts: map[string]time.Duration{
+			// The New York datasource downloads the whole state dataset when it
+			// is first queried, so it gets more headroom than a single lookup.
+			// So do the datasources querying it, which may be the first to.
+			"newyork":                30 * time.Second,
+			api.MergedDatasourceName: 30 * time.Second,
+			api.AutoDatasourceName:   30 * time.Second,
+		},
+	}
+}
+
+// upstreamTimeout returns the deadline for upstream requests to the given datasource
+func (config Config) upstreamTimeout(datasource string) time.Duration {
+	if timeout, ok := config.UpstreamTimeouts[datasource]; ok {
+		return timeout
+	}
+
+	return config.UpstreamTimeout
+}
+
+// upstreamContext derives the context used for upstream requests on behalf of r
+// from the request context, so that a client disconnecting cancels them, and
+// bounds it by the deadline configured for the datasource. It carries an
+// api.QueryReport, see writeQueryReport.
+func (service *Service) upstreamContext(r *http.Request, datasource string) (context.Context, context.CancelFunc) {
+	ctx, _ := api.WithQueryReport(r.Context())
+
+	timeout := service.config.upstreamTimeout(datasource)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// upstreamErrorStatus returns the HTTP status code to respond with when a
+// datasource fails with err
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+
+	if errors.Is(err, api.ErrZipCodeNotFound) || errors.Is(err, api.ErrMarketNotFound) {
+		return http.StatusNotFound
+	}
+
+	if errors.Is(err, api.ErrUnsupportedFilter) {
+		return http.StatusBadRequest
+	}
+
+	if errors.Is(err, api.ErrUpstreamUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeUpstreamError responds with the HTTP status code for err. Errors caused by
+// the request itself, such as filtering on data the datasource does not have, are
+// explained in the body.
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	status := upstreamErrorStatus(err)
+	if status == http.StatusBadRequest {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(status)
+}
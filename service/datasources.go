@@ -0,0 +1,21 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// datasourcesJsonHandler returns the metadata of every available datasource
+// in JSON format
+func (service *Service) datasourcesJsonHandler(w http.ResponseWriter, _ *http.Request) {
+	datasourcesJson, err := json.Marshal(service.datasources)
+	if err != nil {
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(datasourcesJson)
+}
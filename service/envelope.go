@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// The response headers reporting how a query was answered
+const (
+	datasourceHeader = "X-Datasource" // the datasource that answered the query
+	warningHeader    = "X-Warning"    // one per warning, such as finding fewer markets than requested
+)
+
+// ResponseMetadata is how a query was answered
+type ResponseMetadata struct {
+	Datasource api.Datasource // the datasource that answered, the one chosen by the auto datasource or the one requested
+	Warnings   []string       // what the client should know about the answer, such as finding fewer markets than requested
+	DataAsOf   time.Time      // when the data was downloaded from upstream, zero if unknown
+}
+
+// writeQueryReport sets the response headers reporting how the query made with a
+// context from upstreamContext was answered, and returns the metadata reported
+func writeQueryReport(ctx context.Context, w http.ResponseWriter, requested string) ResponseMetadata {
+	report := api.QueryReportFrom(ctx)
+
+	name := requested
+	if chosen := report.Datasource(); chosen != "" {
+		name = chosen
+	}
+
+	datasource, _ := api.LookupDatasource(name)
+
+	metadata := ResponseMetadata{Datasource: datasource, Warnings: report.Warnings(), DataAsOf: report.DataAsOf()}
+
+	w.Header().Set(datasourceHeader, datasource.Name)
+	for _, warning := range metadata.Warnings {
+		w.Header().Add(warningHeader, warning)
+	}
+
+	return metadata
+}
+
+// EnvelopeVersion is the version of the Envelope, which prefixes the paths of the
+// API calls returning it, e.g. /v1/nearestN
+const EnvelopeVersion = 1
+
+// Envelope is the response of the versioned JSON API calls: the markets found along
+// with how they were found. The unversioned API calls return the bare Results.
+type Envelope struct {
+	Version     int                       `json:"version"`
+	Results     []api.FarmersMarketRecord `json:"results"`
+	Requested   int                       `json:"requested"`    // the number of markets requested, -1 for all of them
+	Returned    int                       `json:"returned"`     // the number of Results
+	Datasource  string                    `json:"datasource"`   // the datasource that answered
+	Units       geography.DistanceUnit    `json:"units"`        // of the distance of every result
+	GeneratedAt time.Time                 `json:"generated_at"` // when the response was generated
+	DataAsOf    *time.Time                `json:"data_as_of"`   // when the data was downloaded from upstream, null if unknown
+	Warnings    []string                  `json:"warnings"`     // e.g. fewer markets were found than requested
+}
+
+// NewEnvelope returns the Envelope of the records found by a query for requested markets
+func NewEnvelope(requested int, records []api.FarmersMarketRecord, units geography.DistanceUnit, metadata ResponseMetadata) Envelope {
+	envelope := Envelope{
+		Version:     EnvelopeVersion,
+		Results:     records,
+		Requested:   requested,
+		Returned:    len(records),
+		Datasource:  metadata.Datasource.Name,
+		Units:       units,
+		GeneratedAt: time.Now().UTC(),
+		Warnings:    metadata.Warnings,
+	}
+
+	// Empty lists rather than null, so that clients can always iterate over them
+	if envelope.Results == nil {
+		envelope.Results = []api.FarmersMarketRecord{}
+	}
+
+	if envelope.Warnings == nil {
+		envelope.Warnings = []string{}
+	}
+
+	if !metadata.DataAsOf.IsZero() {
+		dataAsOf := metadata.DataAsOf.UTC()
+		envelope.DataAsOf = &dataAsOf
+	}
+
+	return envelope
+}
+
+// envelopeJsonHandler returns a handler responding to the versioned API call built
+// on internal with an Envelope, in meters unless the units query parameter says otherwise
+func (service *Service) envelopeJsonHandler(internal func(w http.ResponseWriter, r *http.Request, defaultUnit geography.DistanceUnit) (*NearestNTemplateData, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, ok := internal(w, r, geography.Meters)
+		if !ok {
+			return
+		}
+
+		envelopeJson, err := json.Marshal(NewEnvelope(data.Count, data.Records, data.Units, data.ResponseMetadata))
+		if err != nil {
+			service.sugaredLogger.Errorf("marshalling json: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(envelopeJson)
+	}
+}
@@ -8,9 +8,16 @@ import (
 )
 
 func (service *Service) getLocationHTMLHandler(w http.ResponseWriter, _ *http.Request) {
-	err := defaultView.getLocation.Execute(w, defaultGenericPageTemplateData)
+	data, err := NewGetLocationTemplateData(service.datasources, service.defaultDatasource())
 	if err != nil {
-		service.sugaredLogger.Errorf("executing template: %w", err)
+		service.sugaredLogger.Errorf("loading getLocation template data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = defaultView.getLocation.Execute(w, data)
+	if err != nil {
+		service.sugaredLogger.Errorf("executing template: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
@@ -18,7 +25,7 @@ func (service *Service) getLocationHTMLHandler(w http.ResponseWriter, _ *http.Re
 func (service *Service) supportUsHandler(w http.ResponseWriter, _ *http.Request) {
 	err := defaultView.supportUs.Execute(w, defaultGenericPageTemplateData)
 	if err != nil {
-		service.sugaredLogger.Errorf("executing template: %w", err)
+		service.sugaredLogger.Errorf("executing template: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
@@ -34,21 +41,21 @@ func (service *Service) developerResourcesHandler(w http.ResponseWriter, r *http
 
 	markdownHtml, err := docs.RenderHTML(path)
 	if err != nil {
-		service.sugaredLogger.Errorf("rendering html from markdown: %w", err)
+		service.sugaredLogger.Errorf("rendering html from markdown: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	developerResourcesTemplate, err := NewDeveloperResourcesTemplateData(markdownHtml)
 	if err != nil {
-		service.sugaredLogger.Errorf("creating template: %w", err)
+		service.sugaredLogger.Errorf("creating template: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	err = defaultView.developerResources.Execute(w, developerResourcesTemplate)
 	if err != nil {
-		service.sugaredLogger.Errorf("executing template: %w", err)
+		service.sugaredLogger.Errorf("executing template: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
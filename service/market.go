@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jadidbourbaki/gofarm/api"
+)
+
+// marketInternal returns the market given by the datasource and id path variables,
+// and the datasource that answered, as its first return values and true as its last return value
+// if we were able to find it. Otherwise, it returns false as its last return value.
+func (service *Service) marketInternal(w http.ResponseWriter, r *http.Request) (api.FarmersMarketRecord, api.Datasource, bool) {
+	vars := mux.Vars(r)
+
+	datasourceString := vars["datasource"]
+	datasourceApi, ok := service.ApiForDataSource(datasourceString)
+	if !ok {
+		service.sugaredLogger.Errorf("could not find api for datasource: %s", datasourceString)
+		w.WriteHeader(http.StatusNotFound)
+		return api.FarmersMarketRecord{}, api.Datasource{}, false
+	}
+
+	ctx, cancel := service.upstreamContext(r, datasourceString)
+	defer cancel()
+
+	record, err := datasourceApi.Market(ctx, api.MarketID(datasourceString, vars["id"]))
+	if err != nil {
+		service.sugaredLogger.Errorf("market: %v", err)
+		writeUpstreamError(w, err)
+		return api.FarmersMarketRecord{}, api.Datasource{}, false
+	}
+
+	metadata := writeQueryReport(ctx, w, datasourceString)
+
+	return record, metadata.Datasource, true
+}
+
+// marketJsonHandler returns a single Farmers' Market in JSON format
+func (service *Service) marketJsonHandler(w http.ResponseWriter, r *http.Request) {
+	record, _, ok := service.marketInternal(w, r)
+	if !ok {
+		return
+	}
+
+	recordJson, err := json.Marshal(record)
+	if err != nil {
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(recordJson)
+}
+
+// marketHTMLHandler shows a single Farmers' Market
+func (service *Service) marketHTMLHandler(w http.ResponseWriter, r *http.Request) {
+	record, datasource, ok := service.marketInternal(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := NewMarketTemplateData(record, datasource)
+	if err != nil {
+		service.sugaredLogger.Errorf("loading market template data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := defaultView.market.Execute(w, data); err != nil {
+		service.sugaredLogger.Errorf("executing template: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -5,18 +5,20 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/jadidbourbaki/gofarm/api"
 	"github.com/jadidbourbaki/gofarm/geography"
 )
 
 // nearestNInternal returns the records as its first return value and true as its second return value if
 // we were able to get the nearestN records. Otherwise, it returns a false as its second return value.
-func (service *Service) nearestNInternal(w http.ResponseWriter, r *http.Request) (*NearestNTemplateData, bool) {
+// Distances are converted into the unit requested with the units query parameter, or defaultUnit.
+func (service *Service) nearestNInternal(w http.ResponseWriter, r *http.Request, defaultUnit geography.DistanceUnit) (*NearestNTemplateData, bool) {
 	query := r.URL.Query()
 
 	nString := query.Get("n")
 	n, err := strconv.Atoi(nString)
 	if err != nil {
-		service.sugaredLogger.Errorf("incorrect value for n: %w", err)
+		service.sugaredLogger.Errorf("incorrect value for n: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return nil, false
 
@@ -26,7 +28,7 @@ func (service *Service) nearestNInternal(w http.ResponseWriter, r *http.Request)
 	latitude, err := strconv.ParseFloat(latitudeString, 64)
 
 	if err != nil {
-		service.sugaredLogger.Errorf("incorrect value for latitude: %w", err)
+		service.sugaredLogger.Errorf("incorrect value for latitude: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return nil, false
 	}
@@ -35,13 +37,27 @@ func (service *Service) nearestNInternal(w http.ResponseWriter, r *http.Request)
 	longitude, err := strconv.ParseFloat(longitudeString, 64)
 
 	if err != nil {
-		service.sugaredLogger.Errorf("incorrect value for longitude: %w", err)
+		service.sugaredLogger.Errorf("incorrect value for longitude: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return nil, false
 	}
 
+	unit, err := distanceUnitFromQuery(query, defaultUnit)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for units: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	filter, err := filterFromQuery(query)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect filter: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
 	datasourceString := query.Get("datasource")
-	api, ok := service.ApiForDataSource(datasourceString)
+	datasourceApi, ok := service.ApiForDataSource(datasourceString)
 
 	if !ok {
 		service.sugaredLogger.Errorf("could not find api for datasource: %s", datasourceString)
@@ -49,25 +65,34 @@ func (service *Service) nearestNInternal(w http.ResponseWriter, r *http.Request)
 		return nil, false
 	}
 
+	ctx, cancel := service.upstreamContext(r, datasourceString)
+	defer cancel()
+
 	point := geography.HaversinePoint{Latitude: latitude, Longitude: longitude}
-	records, err := api.NearestN(n, point)
+	records, err := datasourceApi.NearestN(ctx, n, point, filter)
 
 	if err != nil {
-		service.sugaredLogger.Errorf("nearestN: %w", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		service.sugaredLogger.Errorf("nearestN: %v", err)
+		writeUpstreamError(w, err)
 		return nil, false
 	}
 
-	data, err := NewNearestNTemplateData(n, records)
+	api.ConvertDistances(records, unit)
+
+	metadata := writeQueryReport(ctx, w, datasourceString)
+
+	data, err := NewNearestNTemplateData(n, records, unit, metadata)
 	if err != nil {
-		service.sugaredLogger.Errorf("loading nearestN template data: %w", err)
+		service.sugaredLogger.Errorf("loading nearestN template data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
 	}
 
 	return &data, true
 }
 
 func (service *Service) nearestNJsonHandler(w http.ResponseWriter, r *http.Request) {
-	data, ok := service.nearestNInternal(w, r)
+	data, ok := service.nearestNInternal(w, r, geography.Meters)
 
 	if !ok {
 		return
@@ -75,7 +100,7 @@ func (service *Service) nearestNJsonHandler(w http.ResponseWriter, r *http.Reque
 
 	recordsJson, err := json.Marshal(data.Records)
 	if err != nil {
-		service.sugaredLogger.Errorf("marshalling json: %w", err)
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -86,7 +111,7 @@ func (service *Service) nearestNJsonHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (service *Service) nearestNHTMLHandler(w http.ResponseWriter, r *http.Request) {
-	data, ok := service.nearestNInternal(w, r)
+	data, ok := service.nearestNInternal(w, r, geography.Miles)
 
 	if !ok {
 		return
@@ -94,7 +119,7 @@ func (service *Service) nearestNHTMLHandler(w http.ResponseWriter, r *http.Reque
 
 	err := defaultView.nearestN.Execute(w, data)
 	if err != nil {
-		service.sugaredLogger.Errorf("executing template: %w", err)
+		service.sugaredLogger.Errorf("executing template: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
@@ -4,17 +4,21 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+
+	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
 )
 
 // nearestNByZipCodeInternal returns the records as its first return value and true as its second return value if
 // we were able to get the nearestN records. Otherwise, it returns a false as its second return value.
-func (service *Service) nearestNByZipCodeInternal(w http.ResponseWriter, r *http.Request) (*NearestNTemplateData, bool) {
+// Distances are converted into the unit requested with the units query parameter, or defaultUnit.
+func (service *Service) nearestNByZipCodeInternal(w http.ResponseWriter, r *http.Request, defaultUnit geography.DistanceUnit) (*NearestNTemplateData, bool) {
 	query := r.URL.Query()
 
 	nString := query.Get("n")
 	n, err := strconv.Atoi(nString)
 	if err != nil {
-		service.sugaredLogger.Errorf("incorrect value for n: %w", err)
+		service.sugaredLogger.Errorf("incorrect value for n: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return nil, false
 
@@ -27,13 +31,27 @@ func (service *Service) nearestNByZipCodeInternal(w http.ResponseWriter, r *http
 	_, err = strconv.Atoi(zipCodeString)
 
 	if err != nil {
-		service.sugaredLogger.Errorf("incorrect value for zipcode: %w", err)
+		service.sugaredLogger.Errorf("incorrect value for zipcode: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	unit, err := distanceUnitFromQuery(query, defaultUnit)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for units: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return nil, false
 	}
 
+	filter, err := filterFromQuery(query)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect filter: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
 	datasourceString := query.Get("datasource")
-	api, ok := service.ApiForDataSource(datasourceString)
+	datasourceApi, ok := service.ApiForDataSource(datasourceString)
 
 	if !ok {
 		service.sugaredLogger.Errorf("could not find api for datasource: %s", datasourceString)
@@ -41,16 +59,25 @@ func (service *Service) nearestNByZipCodeInternal(w http.ResponseWriter, r *http
 		return nil, false
 	}
 
-	records, err := api.NearestNByZipCode(n, zipCodeString)
+	ctx, cancel := service.upstreamContext(r, datasourceString)
+	defer cancel()
+
+	records, err := datasourceApi.NearestNByZipCode(ctx, n, zipCodeString, filter)
 	if err != nil {
-		service.sugaredLogger.Errorf("nearestNByZipCode: %w", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		service.sugaredLogger.Errorf("nearestNByZipCode: %v", err)
+		writeUpstreamError(w, err)
 		return nil, false
 	}
 
-	data, err := NewNearestNTemplateData(n, records)
+	api.ConvertDistances(records, unit)
+
+	metadata := writeQueryReport(ctx, w, datasourceString)
+
+	data, err := NewNearestNTemplateData(n, records, unit, metadata)
 	if err != nil {
-		service.sugaredLogger.Errorf("loading nearestN template data: %w", err)
+		service.sugaredLogger.Errorf("loading nearestN template data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
 	}
 
 	return &data, true
@@ -59,7 +86,7 @@ func (service *Service) nearestNByZipCodeInternal(w http.ResponseWriter, r *http
 // nearestNJsonByZipCodeHandler returns the nearest N Farmers' Markets to a particular zip code
 // in JSON format
 func (service *Service) nearestNJsonByZipCodeHandler(w http.ResponseWriter, r *http.Request) {
-	data, ok := service.nearestNByZipCodeInternal(w, r)
+	data, ok := service.nearestNByZipCodeInternal(w, r, geography.Meters)
 
 	if !ok {
 		return
@@ -67,7 +94,7 @@ func (service *Service) nearestNJsonByZipCodeHandler(w http.ResponseWriter, r *h
 
 	recordsJson, err := json.Marshal(data.Records)
 	if err != nil {
-		service.sugaredLogger.Errorf("marshalling json: %w", err)
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -80,7 +107,7 @@ func (service *Service) nearestNJsonByZipCodeHandler(w http.ResponseWriter, r *h
 // nearestNByZipCodeHTMLHandler returns the nearest N Farmers' Markets to a particular zip code
 // in HTML format
 func (service *Service) nearestNByZipCodeHTMLHandler(w http.ResponseWriter, r *http.Request) {
-	data, ok := service.nearestNByZipCodeInternal(w, r)
+	data, ok := service.nearestNByZipCodeInternal(w, r, geography.Miles)
 
 	if !ok {
 		return
@@ -88,7 +115,7 @@ func (service *Service) nearestNByZipCodeHTMLHandler(w http.ResponseWriter, r *h
 
 	err := defaultView.nearestN.Execute(w, data)
 	if err != nil {
-		service.sugaredLogger.Errorf("executing template: %w", err)
+		service.sugaredLogger.Errorf("executing template: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// distanceUnitFromQuery returns the unit requested with the units query parameter,
+// or defaultUnit if there is none
+func distanceUnitFromQuery(query url.Values, defaultUnit geography.DistanceUnit) (geography.DistanceUnit, error) {
+	unitString := query.Get("units")
+	if unitString == "" {
+		return defaultUnit, nil
+	}
+
+	return geography.ParseDistanceUnit(unitString)
+}
+
+// distanceFromQuery returns the distance query parameter, given in unit, in meters
+func distanceFromQuery(query url.Values, unit geography.DistanceUnit) (float64, error) {
+	distance, err := strconv.ParseFloat(query.Get("distance"), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if distance < 0 || math.IsNaN(distance) || math.IsInf(distance, 0) {
+		return 0, fmt.Errorf("distance must be a non-negative number: %v", distance)
+	}
+
+	return unit.ToMeters(distance), nil
+}
+
+// filterFromQuery returns the filter requested with the snap, fmnp, season, openNow,
+// openAt and inSeasonOn query parameters
+func filterFromQuery(query url.Values) (api.Filter, error) {
+	var filter api.Filter
+
+	for name, value := range map[string]*bool{
+		"snap": &filter.SnapStatus,
+		"fmnp": &filter.FarmersMarketNutritionProgram,
+	} {
+		if !query.Has(name) {
+			continue
+		}
+
+		parsed, err := strconv.ParseBool(query.Get(name))
+		if err != nil {
+			return filter, fmt.Errorf("incorrect value for %s: %w", name, err)
+		}
+
+		*value = parsed
+	}
+
+	if query.Has("season") {
+		season, err := api.ParseSeason(query.Get("season"))
+		if err != nil {
+			return filter, fmt.Errorf("incorrect value for season: %w", err)
+		}
+
+		filter.Season = season
+	}
+
+	if query.Has("openNow") && query.Has("openAt") {
+		return filter, fmt.Errorf("openNow and openAt are mutually exclusive")
+	}
+
+	if query.Has("openNow") {
+		openNow, err := strconv.ParseBool(query.Get("openNow"))
+		if err != nil {
+			return filter, fmt.Errorf("incorrect value for openNow: %w", err)
+		}
+
+		if openNow {
+			filter.OpenAt = time.Now()
+		}
+	}
+
+	if query.Has("openAt") {
+		openAt, err := time.Parse(time.RFC3339, query.Get("openAt"))
+		if err != nil {
+			return filter, fmt.Errorf("incorrect value for openAt: %w", err)
+		}
+
+		filter.OpenAt = openAt
+	}
+
+	if query.Has("inSeasonOn") {
+		inSeasonOn, err := time.Parse(time.DateOnly, query.Get("inSeasonOn"))
+		if err != nil {
+			return filter, fmt.Errorf("incorrect value for inSeasonOn: %w", err)
+		}
+
+		filter.InSeasonOn = inSeasonOn
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/api"
+)
+
+// RefreshStatus is what operators need to know about the background refreshes
+// of a cached datasource
+type RefreshStatus struct {
+	Datasource          string    `json:"datasource"`
+	LastAttempt         time.Time `json:"last_attempt"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastError           string    `json:"last_error,omitempty"` // error of the last attempt, empty if it succeeded
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	NextAttempt         time.Time `json:"next_attempt"`
+}
+
+// refreshScheduler refreshes the cached (i.e. not live) datasources periodically.
+// A datasource keeps serving its last good snapshot while a refresh is in flight
+// or failing, see api.FarmersMarketApi.Refresh.
+type refreshScheduler struct {
+	mu       sync.Mutex
+	statuses map[string]*RefreshStatus
+}
+
+// refreshDelay returns how long to wait before the next refresh of a datasource
+// that has failed to refresh consecutiveFailures times in a row. After a success
+// it is the refresh interval, after failures it backs off exponentially from
+// RefreshMinBackoff up to RefreshMaxBackoff. Either way it is randomly spread by
+// up to RefreshJitter (a fraction) in both directions so that refreshes of different
+// datasources, or of different instances of the service, do not line up.
+func (config Config) refreshDelay(consecutiveFailures int, random float64) time.Duration {
+	delay := config.RefreshInterval
+
+	if consecutiveFailures > 0 {
+		delay = config.RefreshMinBackoff
+		if delay <= 0 {
+			delay = time.Second
+		}
+
+		for i := 1; i < consecutiveFailures && delay < config.RefreshMaxBackoff; i++ {
+			delay *= 2
+		}
+
+		delay = min(delay, config.RefreshMaxBackoff)
+	}
+
+	// random is in [0, 1), spread it over [-jitter, jitter)
+	jitter := (2*random - 1) * config.RefreshJitter
+
+	return time.Duration(float64(delay) * (1 + jitter))
+}
+
+// startRefreshing starts a goroutine per cached datasource that refreshes it right
+// away and then periodically, until the service is shut down. Nothing is refreshed
+// in the background if the refresh interval is not positive, the datasources then
+// load lazily on the first request instead. Composite datasources have nothing of
+// their own to refresh, the datasources they query are refreshed on their own.
+func (service *Service) startRefreshing() {
+	service.refreshes.statuses = make(map[string]*RefreshStatus)
+
+	if service.config.RefreshInterval <= 0 {
+		return
+	}
+
+	for _, datasource := range service.datasources {
+		if datasource.Capabilities.Live || datasource.Composite {
+			continue
+		}
+
+		service.refreshes.statuses[datasource.Name] = &RefreshStatus{Datasource: datasource.Name}
+
+		go service.refreshPeriodically(datasource.Name)
+	}
+}
+
+// refreshPeriodically refreshes a datasource until the service is shut down
+func (service *Service) refreshPeriodically(datasource string) {
+	for {
+		consecutiveFailures := service.refreshDatasource(datasource)
+
+		delay := service.config.refreshDelay(consecutiveFailures, rand.Float64())
+
+		service.refreshes.mu.Lock()
+		service.refreshes.statuses[datasource].NextAttempt = time.Now().Add(delay)
+		service.refreshes.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-service.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// refreshDatasource refreshes a datasource once, records the outcome in its
+// RefreshStatus and returns the number of consecutive failures so far
+func (service *Service) refreshDatasource(datasource string) int {
+	datasourceApi := service.apis[datasource]
+
+	// Rows skipped while reading the dataset are reported as warnings
+	ctx, report := api.WithQueryReport(context.Background())
+
+	ctx, cancel := context.WithCancel(ctx)
+	if timeout := service.config.upstreamTimeout(datasource); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	attempt := time.Now()
+	err := datasourceApi.Refresh(ctx)
+
+	for _, warning := range report.Warnings() {
+		service.sugaredLogger.Warnw("refreshing datasource", "datasource", datasource, "warning", warning)
+	}
+
+	service.refreshes.mu.Lock()
+	defer service.refreshes.mu.Unlock()
+
+	status := service.refreshes.statuses[datasource]
+	status.LastAttempt = attempt
+
+	if err != nil {
+		status.LastError = err.Error()
+		status.ConsecutiveFailures++
+		service.sugaredLogger.Errorw("refreshing datasource failed",
+			"datasource", datasource,
+			"consecutive_failures", status.ConsecutiveFailures,
+			"error", err,
+		)
+
+		return status.ConsecutiveFailures
+	}
+
+	status.LastSuccess = time.Now()
+	status.LastError = ""
+	status.ConsecutiveFailures = 0
+	service.sugaredLogger.Infow("refreshed datasource",
+		"datasource", datasource,
+		"duration", status.LastSuccess.Sub(attempt),
+	)
+
+	return 0
+}
+
+// RefreshStatuses returns the RefreshStatus of every cached datasource sorted by name
+func (service *Service) RefreshStatuses() []RefreshStatus {
+	service.refreshes.mu.Lock()
+	defer service.refreshes.mu.Unlock()
+
+	statuses := []RefreshStatus{}
+	for _, datasource := range service.datasources {
+		if status, ok := service.refreshes.statuses[datasource.Name]; ok {
+			statuses = append(statuses, *status)
+		}
+	}
+
+	return statuses
+}
+
+// refreshStatusJsonHandler returns the RefreshStatus of every cached datasource in JSON format
+func (service *Service) refreshStatusJsonHandler(w http.ResponseWriter, _ *http.Request) {
+	statusesJson, err := json.Marshal(service.RefreshStatuses())
+	if err != nil {
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(statusesJson)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshDelay(t *testing.T) {
+	config := DefaultConfig()
+	config.RefreshInterval = 6 * time.Hour
+	config.RefreshJitter = 0.1
+	config.RefreshMinBackoff = time.Minute
+	config.RefreshMaxBackoff = time.Hour
+
+	// random = 0.5 means no jitter
+	require.Equal(t, 6*time.Hour, config.refreshDelay(0, 0.5))
+	require.Equal(t, time.Minute, config.refreshDelay(1, 0.5))
+	require.Equal(t, 2*time.Minute, config.refreshDelay(2, 0.5))
+	require.Equal(t, 32*time.Minute, config.refreshDelay(6, 0.5))
+	require.Equal(t, time.Hour, config.refreshDelay(7, 0.5))
+	require.Equal(t, time.Hour, config.refreshDelay(1000, 0.5))
+
+	require.Equal(t, 324*time.Minute, config.refreshDelay(0, 0))
+	require.InDelta(t, float64(396*time.Minute), float64(config.refreshDelay(0, 0.999999)), float64(time.Second))
+}
+
+func TestRefreshStatusOffline(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+
+	config := newTestConfig(t)
+	config.ApiOptions.NewYork.BaseURL = failing.URL
+	config.ApiOptions.NewYork.Guard = nil // not retried, so that it fails right away
+
+	service := New(config)
+	t.Cleanup(service.Shutdown)
+
+	server := newTestServer(t, service)
+
+	var statuses []RefreshStatus
+	require.Eventually(t, func() bool {
+		getJson(t, server, "/datasources/status", &statuses)
+		return len(statuses) == 1 && !statuses[0].NextAttempt.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Only the datasources that are not live are refreshed. The merged and auto
+	// datasources share the New York one, which is only refreshed once.
+	require.Equal(t, "newyork", statuses[0].Datasource)
+
+	for _, status := range statuses {
+		require.NotEmpty(t, status.LastError)
+		require.True(t, status.LastSuccess.IsZero())
+		require.True(t, status.NextAttempt.After(status.LastAttempt))
+	}
+}
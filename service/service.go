@@ -1,8 +1,11 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jadidbourbaki/gofarm/api"
@@ -12,35 +15,116 @@ import (
 // Service contains all the state we need for the Farmers' Market service
 type Service struct {
 	apis          map[string]api.FarmersMarketApi // a map from the datasource to a specific api
+	datasources   []api.Datasource                // metadata for every datasource in apis, sorted by name
+	config        Config
+	zipCodeCache  *api.CachingGeocoder // nil unless the service created the api options itself
+	refreshes     refreshScheduler     // state of the background refreshes of cached datasources
+	stop          chan struct{}        // closed on Shutdown to stop background goroutines
 	logger        zap.Logger
 	sugaredLogger zap.SugaredLogger
 }
 
-// loadApis loads all the available APIs for Farmers' Market Data
+// loadApis loads all the available APIs for Farmers' Market Data from the
+// datasource registry in the api package, or the ones in config.Datasources
 func (service *Service) loadApis() {
 	service.apis = make(map[string]api.FarmersMarketApi)
+	service.datasources = nil
 
-	// Initialize the USDA data source
-	usdaApi, err := api.NewUSDAFarmersMarketApi()
-	if err != nil {
-		service.sugaredLogger.Fatalf("could not load api: %w", err)
+	for _, name := range service.config.Datasources {
+		if _, ok := api.LookupDatasource(name); !ok {
+			service.sugaredLogger.Fatalf("unknown datasource: %s", name)
+		}
+	}
+
+	for _, datasource := range api.Datasources() {
+		if len(service.config.Datasources) == 0 || slices.Contains(service.config.Datasources, datasource.Name) {
+			service.datasources = append(service.datasources, datasource)
+		}
 	}
 
-	service.apis["usda"] = usdaApi
+	if service.config.ApiOptions == nil {
+		options, err := api.NewDefaultOptions()
+		if err != nil {
+			service.sugaredLogger.Fatalf("could not load api options: %v", err)
+		}
 
-	newyorkApi, err := api.NewNewYorkMarketApi()
-	if err != nil {
-		service.sugaredLogger.Fatalf("could not load api: %w", err)
+		geocoder, err := api.NewGeocoderChain(service.config.Geocoders, options)
+		if err != nil {
+			service.sugaredLogger.Fatalf("could not load geocoders: %v", err)
+		}
+
+		if options.USDA == nil {
+			service.sugaredLogger.Warn("usda credentials not set, the usda datasource is not served")
+		}
+
+		if options.GeoNames == nil {
+			service.sugaredLogger.Warn("geonames credentials not set, zip codes are only resolved with the embedded gazetteer")
+		}
+
+		if gazetteer, err := api.DefaultGazetteer(); err == nil {
+			service.sugaredLogger.Infof("embedded gazetteer contains %d zip codes", gazetteer.Len())
+		}
+
+		service.zipCodeCache, err = api.NewCachingGeocoder(geocoder, service.config.ZipCodeCache)
+		if err != nil {
+			service.sugaredLogger.Fatalf("could not load zip code cache: %v", err)
+		}
+
+		options.Geocoder = service.zipCodeCache
+		options.USDAMaxMiles = service.config.USDAMaxMiles
+		options.USDACache = service.config.USDACache
+		service.config.ApiOptions = &options
+	}
+
+	options := *service.config.ApiOptions
+	if len(service.config.Datasources) > 0 {
+		options.Datasources = service.config.Datasources
 	}
 
-	service.apis["newyork"] = newyorkApi
+	// The composite datasources query the others, so they are constructed last and
+	// share the FarmersMarketApis of the others
+	options.Apis = service.apis
+
+	var constructed []string
+	for _, composite := range []bool{false, true} {
+		if composite && len(service.config.Datasources) == 0 && len(constructed) > 0 {
+			// Only the datasources that could be constructed are chosen from and merged
+			options.Datasources = constructed
+		}
+
+		for idx, datasource := range service.datasources {
+			if datasource.Composite != composite {
+				continue
+			}
+
+			datasourceApi, err := datasource.New(options)
+			if errors.Is(err, api.ErrClientNotConfigured) && len(service.config.Datasources) == 0 {
+				// Datasources that were not asked for are left out rather than failing
+				// the start, e.g. the usda datasource without its credentials
+				service.sugaredLogger.Warnf("datasource %s not served: %v", datasource.Name, err)
+				continue
+			}
+
+			if err != nil {
+				service.sugaredLogger.Fatalf("could not load api for datasource %s: %v", datasource.Name, err)
+			}
+
+			service.apis[datasource.Name] = datasourceApi
+			service.datasources[idx].Capabilities = datasource.CapabilitiesOf(datasourceApi)
+			constructed = append(constructed, datasource.Name)
+		}
+	}
 
+	service.datasources = slices.DeleteFunc(service.datasources, func(datasource api.Datasource) bool {
+		_, ok := service.apis[datasource.Name]
+		return !ok
+	})
 }
 
 // New creates a new service struct and initializes loggers and the API as
 // well as other internal state
-func New() *Service {
-	service := &Service{}
+func New(config Config) *Service {
+	service := &Service{config: config, stop: make(chan struct{})}
 
 	// Initialize the logger.
 	// No need to handle errors here, if even the logger isn't running
@@ -49,19 +133,63 @@ func New() *Service {
 	service.sugaredLogger = *service.logger.Sugar()
 
 	service.loadApis()
+	service.startRefreshing()
+
+	if service.zipCodeCache != nil && service.config.ZipCodeCacheStatsInterval > 0 {
+		go service.logZipCodeCacheStatsPeriodically(service.config.ZipCodeCacheStatsInterval)
+	}
 
 	return service
 }
 
+// logZipCodeCacheStats logs the counters of the zip code cache
+func (service *Service) logZipCodeCacheStats() {
+	stats := service.zipCodeCache.Stats()
+	service.sugaredLogger.Infow("zip code cache",
+		"hits", stats.Hits,
+		"negative_hits", stats.NegativeHits,
+		"misses", stats.Misses,
+		"persist_errors", stats.PersistErrors,
+		"entries", stats.Entries,
+	)
+}
+
+// logZipCodeCacheStatsPeriodically logs the counters of the zip code cache
+// every interval until the service is shut down
+func (service *Service) logZipCodeCacheStatsPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			service.logZipCodeCacheStats()
+		case <-service.stop:
+			return
+		}
+	}
+}
+
 func (service *Service) ApiForDataSource(dataSource string) (api.FarmersMarketApi, bool) {
 	api, ok := service.apis[dataSource]
 	return api, ok
 }
 
-// Run runs the service
-func (service *Service) Run(port int, enableTls bool) {
+// defaultDatasource returns the datasource selected when a client has not expressed
+// a preference, api.DefaultDatasourceName unless it is not served
+func (service *Service) defaultDatasource() string {
+	if _, ok := service.apis[api.DefaultDatasourceName]; ok || len(service.datasources) == 0 {
+		return api.DefaultDatasourceName
+	}
+
+	return service.datasources[0].Name
+}
+
+// Handler loads the templates and returns the http.Handler serving every
+// route of the service
+func (service *Service) Handler() (http.Handler, error) {
 	if err := loadDefaultViewAndTemplates(); err != nil {
-		service.sugaredLogger.Fatalf("could not load default view: %w", err)
+		return nil, fmt.Errorf("could not load default view: %w", err)
 	}
 
 	router := mux.NewRouter()
@@ -70,6 +198,24 @@ func (service *Service) Run(port int, enableTls bool) {
 	router.HandleFunc("/nearestNHtml", service.nearestNHTMLHandler).Methods("GET")
 	router.HandleFunc("/nearestNJsonByZipCode", service.nearestNJsonByZipCodeHandler).Methods("GET")
 	router.HandleFunc("/nearestNHtmlByZipCode", service.nearestNByZipCodeHTMLHandler).Methods("GET")
+	router.HandleFunc("/withinRadiusJson", service.withinRadiusJsonHandler).Methods("GET")
+	router.HandleFunc("/withinRadiusHtml", service.withinRadiusHTMLHandler).Methods("GET")
+	router.HandleFunc("/withinRadiusJsonByZipCode", service.withinRadiusJsonByZipCodeHandler).Methods("GET")
+	router.HandleFunc("/withinRadiusHtmlByZipCode", service.withinRadiusByZipCodeHTMLHandler).Methods("GET")
+	router.HandleFunc("/withinBoundsJson", service.withinBoundsJsonHandler).Methods("GET")
+
+	// The versioned API calls respond with an Envelope rather than the bare records
+	versioned := router.PathPrefix(fmt.Sprintf("/v%d", EnvelopeVersion)).Subrouter()
+	versioned.HandleFunc("/nearestN", service.envelopeJsonHandler(service.nearestNInternal)).Methods("GET")
+	versioned.HandleFunc("/nearestNByZipCode", service.envelopeJsonHandler(service.nearestNByZipCodeInternal)).Methods("GET")
+	versioned.HandleFunc("/withinRadius", service.envelopeJsonHandler(service.withinRadiusInternal)).Methods("GET")
+	versioned.HandleFunc("/withinRadiusByZipCode", service.envelopeJsonHandler(service.withinRadiusByZipCodeInternal)).Methods("GET")
+	versioned.HandleFunc("/withinBounds", service.envelopeJsonHandler(service.withinBoundsInternal)).Methods("GET")
+
+	router.HandleFunc("/markets/{datasource}/{id}", service.marketJsonHandler).Methods("GET")
+	router.HandleFunc("/markets/{datasource}/{id}/html", service.marketHTMLHandler).Methods("GET")
+	router.HandleFunc("/datasources", service.datasourcesJsonHandler).Methods("GET")
+	router.HandleFunc("/datasources/status", service.refreshStatusJsonHandler).Methods("GET")
 	router.HandleFunc("/", service.getLocationHTMLHandler).Methods("GET")
 
 	router.HandleFunc("/about", service.supportUsHandler).Methods("GET")
@@ -77,6 +223,16 @@ func (service *Service) Run(port int, enableTls bool) {
 	router.HandleFunc("/developerResources/{path}", service.developerResourcesHandler).Methods("GET")
 	router.HandleFunc("/developerResources", service.developerResourcesHandler).Methods("GET")
 
+	return router, nil
+}
+
+// Run runs the service
+func (service *Service) Run(port int, enableTls bool) {
+	router, err := service.Handler()
+	if err != nil {
+		service.sugaredLogger.Fatal(err)
+	}
+
 	runOnPort := fmt.Sprintf(":%v", port)
 	service.sugaredLogger.Infof("running on port %s", runOnPort)
 
@@ -92,7 +248,7 @@ func (service *Service) Run(port int, enableTls bool) {
 		service.sugaredLogger.Fatal(err)
 	}
 
-	err := http.ListenAndServeTLS(runOnPort, defaultTlsCredentials.certificate, defaultTlsCredentials.key, router)
+	err = http.ListenAndServeTLS(runOnPort, defaultTlsCredentials.certificate, defaultTlsCredentials.key, router)
 	if err != nil {
 		service.sugaredLogger.Fatal(err)
 	}
@@ -100,5 +256,15 @@ func (service *Service) Run(port int, enableTls bool) {
 
 // Shutdown should be called prior to closing the service
 func (service *Service) Shutdown() {
+	close(service.stop)
+
+	if service.zipCodeCache != nil {
+		service.logZipCodeCacheStats()
+
+		if err := service.zipCodeCache.Close(); err != nil {
+			service.sugaredLogger.Errorf("closing zip code cache: %v", err)
+		}
+	}
+
 	service.logger.Sync()
 }
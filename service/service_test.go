@@ -0,0 +1,641 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
+	"github.com/stretchr/testify/require"
+)
+
+const testUSDAResponse = `{
+	"data": [
+		{
+			"distance": "0.2492313843284663",
+			"listing_id": "312052",
+			"listing_name": "Potsdam Farmers Market",
+			"location_city": "New York",
+			"location_state": "New York",
+			"location_street": "3 Riverside Dr",
+			"location_x": "-73.98557883420489",
+			"location_y": "40.7805342225485",
+			"location_zipcode": "10023"
+		}
+	]
+}`
+
+const testNewYorkResponse = `[
+	{
+		"market_name": "Albany County Farmers' Market",
+		"address_line_1": "51 S. Pearl St",
+		"city": "Albany",
+		"state": "NY",
+		"zip": "12207",
+		"contact": "Jevan Dollard",
+		"phone": "5184652143",
+		"operation_hours": "Sun 10am-2pm",
+		"operation_season": "May 4-November 23",
+		"operation_months_code": "P,M",
+		"fmnp": "N",
+		"snap_status": "Y",
+		"latitude": "42.64819",
+		"longitude": "-73.75383"
+	},
+	{
+		"market_name": "82nd Street Greenmarket",
+		"address_line_1": "408 East 82nd Street",
+		"city": "New York",
+		"state": "NY",
+		"zip": "10128",
+		"operation_hours": "Sat 9am-2:30pm",
+		"operation_season": "Year-round",
+		"operation_months_code": "YR",
+		"fmnp": "Y",
+		"snap_status": "Y",
+		"latitude": "40.77394",
+		"longitude": "-73.9506"
+	}
+]`
+
+const testGeoNamesResponse = `{"postalcodes": [{"postalcode": "10023", "lat": 40.776, "lng": -73.982}]}`
+
+// newTestUpstream returns a server that always responds with body
+func newTestUpstream(t *testing.T, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newTestConfig returns the default config with every upstream API replaced
+// by a local stand-in
+func newTestConfig(t *testing.T) Config {
+	usda := api.NewUSDAClient("key", api.NewUpstreamGuard("usda", api.USDAUpstreamPolicy))
+	usda.BaseURL = newTestUpstream(t, testUSDAResponse).URL
+
+	newYork := api.NewNewYorkClient(api.NewUpstreamGuard("newyork", api.SocrataUpstreamPolicy))
+	newYork.BaseURL = newTestUpstream(t, testNewYorkResponse).URL
+
+	geonames := api.NewGeoNamesClient("user", api.NewUpstreamGuard("geonames", api.GeoNamesUpstreamPolicy))
+	geonames.BaseURL = newTestUpstream(t, testGeoNamesResponse).URL
+
+	config := DefaultConfig()
+	config.ApiOptions = &api.Options{USDA: usda, NewYork: newYork, GeoNames: geonames, Geocoder: geonames}
+
+	return config
+}
+
+// newTestService returns a server running the whole service, with every
+// upstream API replaced by a local stand-in.
+func newTestService(t *testing.T) *httptest.Server {
+	return newTestServer(t, New(newTestConfig(t)))
+}
+
+// newTestServer returns a server running service
+func newTestServer(t *testing.T, service *Service) *httptest.Server {
+	handler, err := service.Handler()
+	require.Nil(t, err)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// getJson makes a GET request to the test service and decodes the JSON response into v
+func getJson(t *testing.T, server *httptest.Server, path string, v any) {
+	res, err := http.Get(server.URL + path)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Nil(t, json.NewDecoder(res.Body).Decode(v))
+}
+
+func TestNearestNJsonOffline(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=1&latitude=40.78&longitude=-73.95&datasource=newyork", &records)
+
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+}
+
+func TestNearestNJsonFewerThanN(t *testing.T) {
+	server := newTestService(t)
+
+	// The USDA stand-in only lists one market however wide the radius
+	res, err := http.Get(server.URL + "/nearestNJson?n=3&latitude=40.78&longitude=-73.95&datasource=usda")
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, []string{"found 1 of the 3 markets requested within 500 miles"}, res.Header.Values("X-Warning"))
+
+	var records []api.FarmersMarketRecord
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&records))
+	require.Equal(t, 1, len(records))
+}
+
+func TestNearestNJsonByZipCodeOffline(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJsonByZipCode?n=1&zipCode=10023&datasource=usda", &records)
+
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Potsdam Farmers Market", records[0].Name)
+}
+
+func TestNearestNJsonAllDatasources(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=-1&latitude=40.78&longitude=-73.95&datasource=all", &records)
+
+	require.Equal(t, 3, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+	require.Equal(t, "Potsdam Farmers Market", records[1].Name)
+	require.Equal(t, []string{"usda:312052@40.78053_-73.98558"}, records[1].MergedFrom)
+
+	var record api.FarmersMarketRecord
+	getJson(t, server, strings.TrimSuffix(marketPath(records[1].ID), "/html"), &record)
+	require.Equal(t, records[1].ID, record.ID)
+}
+
+func TestCompositeDatasourcesShareTheOthersOffline(t *testing.T) {
+	downloads := 0
+	newYork := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads++
+		w.Write([]byte(testNewYorkResponse))
+	}))
+	t.Cleanup(newYork.Close)
+
+	config := newTestConfig(t)
+	config.ApiOptions.NewYork.BaseURL = newYork.URL
+	config.RefreshInterval = 0 // loaded by the first query instead
+
+	server := newTestServer(t, New(config))
+
+	for _, datasource := range []string{"all", "auto", "newyork"} {
+		var records []api.FarmersMarketRecord
+		getJson(t, server, "/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource="+datasource, &records)
+		require.Equal(t, "Albany County Farmers' Market", records[0].Name, datasource)
+	}
+
+	require.Equal(t, 1, downloads)
+
+	// The merged datasource is only live if everything it merges is
+	var datasources []api.Datasource
+	getJson(t, server, "/datasources", &datasources)
+
+	for _, datasource := range datasources {
+		if datasource.Name == api.MergedDatasourceName {
+			require.False(t, datasource.Capabilities.Live)
+		}
+	}
+}
+
+func TestNearestNJsonAutoDatasource(t *testing.T) {
+	server := newTestService(t)
+
+	for _, test := range []struct {
+		latitude, longitude float64
+		datasource          string
+		name                string
+	}{
+		{42.65, -73.75, "newyork", "Albany County Farmers' Market"}, // Albany
+		{42.36, -71.06, "usda", "Potsdam Farmers Market"},           // Boston
+	} {
+		res, err := http.Get(fmt.Sprintf("%s/nearestNJson?n=1&latitude=%v&longitude=%v&datasource=auto", server.URL, test.latitude, test.longitude))
+		require.Nil(t, err)
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, test.datasource, res.Header.Get("X-Datasource"))
+
+		var records []api.FarmersMarketRecord
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&records))
+		res.Body.Close()
+		require.Equal(t, test.name, records[0].Name)
+	}
+}
+
+func TestAutoDatasourceMarketOffline(t *testing.T) {
+	server := newTestService(t)
+
+	// The USDA remembers the markets the auto datasource found through it
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=1&latitude=42.36&longitude=-71.06&datasource=auto", &records)
+	require.Equal(t, "usda:312052@40.78053_-73.98558", records[0].ID)
+
+	var record api.FarmersMarketRecord
+	getJson(t, server, strings.TrimSuffix(marketPath(records[0].ID), "/html"), &record)
+	require.Equal(t, records[0].ID, record.ID)
+	require.Equal(t, "Potsdam Farmers Market", record.Name)
+
+	res, err := http.Get(server.URL + marketPath(records[0].ID))
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestNearestNHtmlOffline(t *testing.T) {
+	server := newTestService(t)
+
+	res, err := http.Get(server.URL + "/nearestNHtml?n=1&latitude=42.65&longitude=-73.75&datasource=newyork")
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.Nil(t, err)
+	require.Contains(t, string(body), "Albany County Farmers&#39; Market")
+	require.Contains(t, string(body), `<a href="tel:5184652143">5184652143</a>`)
+	require.Contains(t, string(body), "Spring</span>")
+	require.Contains(t, string(body), `<a href="/markets/newyork/`)
+}
+
+func TestHtmlWithoutTemplatesOffline(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource=newyork", &records)
+
+	_, localID, err := api.SplitMarketID(records[0].ID)
+	require.Nil(t, err)
+
+	// Pages are not rendered without their templates
+	defaultView.loaded = false
+	t.Cleanup(func() { defaultView.loaded = true })
+
+	for _, path := range []string{"/markets/newyork/" + localID + "/html", "/nearestNHtml?n=1&latitude=42.65&longitude=-73.75&datasource=newyork"} {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.Nil(t, err)
+
+		require.Equal(t, http.StatusInternalServerError, res.StatusCode, path)
+		require.NotContains(t, string(body), "<html>", path)
+	}
+}
+
+func TestMarketHtmlEscapesListings(t *testing.T) {
+	require.Nil(t, loadDefaultViewAndTemplates())
+
+	// USDA listings are submitted by the markets themselves
+	record := api.FarmersMarketRecord{
+		ID:       "usda:1",
+		Name:     "<script>alert(1)</script>",
+		Website:  "javascript:alert(2)",
+		ImageURL: `x" onerror="alert(3)`,
+		SocialMedia: &api.FarmersMarketSocialMedia{
+			Facebook: "javascript:alert(4)",
+		},
+		Contact: &api.FarmersMarketContact{
+			Email: `market@example.com"><script>alert(5)</script>`,
+		},
+	}
+
+	data, err := NewMarketTemplateData(record, api.Datasource{Title: "USDA"})
+	require.Nil(t, err)
+
+	var body strings.Builder
+	require.Nil(t, defaultView.market.Execute(&body, data))
+
+	require.NotContains(t, body.String(), "<script>alert")
+	require.NotContains(t, body.String(), "javascript:")
+	require.NotContains(t, body.String(), `onerror="alert`)
+	require.Contains(t, body.String(), "&lt;script&gt;alert(1)&lt;/script&gt;")
+}
+
+func TestMarketOffline(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource=newyork", &records)
+
+	datasource, localID, err := api.SplitMarketID(records[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, "newyork", datasource)
+
+	var record api.FarmersMarketRecord
+	getJson(t, server, "/markets/newyork/"+localID, &record)
+	require.Equal(t, records[0].ID, record.ID)
+	require.Equal(t, "Albany County Farmers' Market", record.Name)
+	require.Equal(t, float64(-1), record.Distance)
+
+	res, err := http.Get(server.URL + "/markets/newyork/" + localID + "/html")
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.Nil(t, err)
+	require.Contains(t, string(body), "Albany County Farmers&#39; Market")
+	require.Contains(t, string(body), "Listed by New York State")
+
+	for _, path := range []string{"/markets/newyork/0123456789abcdef", "/markets/nowhere/" + localID, "/markets/newyork/0123456789abcdef/html"} {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusNotFound, res.StatusCode, path)
+	}
+}
+
+func TestDatasourcesOffline(t *testing.T) {
+	server := newTestService(t)
+
+	var datasources []api.Datasource
+	getJson(t, server, "/datasources", &datasources)
+
+	require.Equal(t, len(api.Datasources()), len(datasources))
+}
+
+func TestConfiguredDatasourcesOffline(t *testing.T) {
+	// Without a USDA client, only the others can be served
+	config := newTestConfig(t)
+	config.ApiOptions.USDA = nil
+	config.Datasources = []string{"auto", "newyork"}
+
+	server := newTestServer(t, New(config))
+
+	var datasources []api.Datasource
+	getJson(t, server, "/datasources", &datasources)
+
+	require.Equal(t, 2, len(datasources))
+	require.Equal(t, "auto", datasources[0].Name)
+	require.Equal(t, "newyork", datasources[1].Name)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=2&latitude=40.78&longitude=-73.95&datasource=auto", &records)
+	require.Equal(t, 2, len(records))
+
+	res, err := http.Get(server.URL + "/nearestNJson?n=2&latitude=40.78&longitude=-73.95&datasource=usda")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestWithoutCredentialsOffline(t *testing.T) {
+	// Without a USDA client, and without datasources asked for, the others are served
+	config := newTestConfig(t)
+	config.ApiOptions.USDA = nil
+
+	server := newTestServer(t, New(config))
+
+	var datasources []api.Datasource
+	getJson(t, server, "/datasources", &datasources)
+
+	names := []string{}
+	for _, datasource := range datasources {
+		names = append(names, datasource.Name)
+	}
+	require.NotContains(t, names, "usda")
+	require.Contains(t, names, "newyork")
+
+	for _, datasource := range []string{"all", "auto"} {
+		var records []api.FarmersMarketRecord
+		getJson(t, server, "/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource="+datasource, &records)
+		require.Equal(t, "Albany County Farmers' Market", records[0].Name, datasource)
+	}
+}
+
+func TestNearestNJsonUnits(t *testing.T) {
+	server := newTestService(t)
+
+	var meters []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=2&latitude=40.78&longitude=-73.95&datasource=newyork", &meters)
+
+	var kilometers []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=2&latitude=40.78&longitude=-73.95&datasource=newyork&units=km", &kilometers)
+
+	require.Equal(t, 2, len(meters))
+	require.Equal(t, 2, len(kilometers))
+
+	for idx := range meters {
+		require.Equal(t, geography.Meters, meters[idx].DistanceUnits)
+		require.Equal(t, geography.Kilometers, kilometers[idx].DistanceUnits)
+		require.InDelta(t, meters[idx].Distance/1000, kilometers[idx].Distance, 1e-9)
+	}
+
+	// Albany is about 209km from the Upper East Side as the crow flies
+	require.InDelta(t, 209, kilometers[1].Distance, 5)
+
+	res, err := http.Get(server.URL + "/nearestNJson?n=2&latitude=40.78&longitude=-73.95&datasource=newyork&units=furlongs")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestNearestNJsonByZipCodeDistanceIsComputed(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJsonByZipCode?n=1&zipCode=10023&datasource=usda&units=mi", &records)
+
+	// The USDA reports 0.249 miles from its own reference point, we compute the
+	// distance from the center of the zip code returned by the geocoder instead.
+	require.Equal(t, 1, len(records))
+	require.Equal(t, geography.Miles, records[0].DistanceUnits)
+	require.InDelta(t, 0.3, records[0].Distance, 0.1)
+}
+
+func TestWithinRadiusJsonOffline(t *testing.T) {
+	server := newTestService(t)
+
+	// Albany is about 209km away, so only the 82nd Street Greenmarket is within 100km
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/withinRadiusJson?distance=100&units=km&latitude=40.78&longitude=-73.95&datasource=newyork", &records)
+
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+	require.Equal(t, geography.Kilometers, records[0].DistanceUnits)
+
+	getJson(t, server, "/withinRadiusJson?distance=300000&latitude=40.78&longitude=-73.95&datasource=newyork", &records)
+	require.Equal(t, 2, len(records))
+	require.Less(t, records[0].Distance, records[1].Distance)
+
+	res, err := http.Get(server.URL + "/withinRadiusJson?distance=-1&latitude=40.78&longitude=-73.95&datasource=newyork")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestWithinRadiusJsonByZipCodeOffline(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/withinRadiusJsonByZipCode?distance=1&units=mi&zipCode=10023&datasource=usda", &records)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Potsdam Farmers Market", records[0].Name)
+
+	// The market is about 0.3 miles from the center of the zip code
+	getJson(t, server, "/withinRadiusJsonByZipCode?distance=0.1&units=mi&zipCode=10023&datasource=usda", &records)
+	require.Empty(t, records)
+}
+
+func TestWithinBoundsJsonOffline(t *testing.T) {
+	server := newTestService(t)
+
+	// A box around New York State
+	bounds := "southWestLatitude=40.5&southWestLongitude=-79.8&northEastLatitude=45&northEastLongitude=-71.8"
+
+	res, err := http.Get(server.URL + "/withinBoundsJson?datasource=newyork&" + bounds)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	var records []api.FarmersMarketRecord
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&records))
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "false", res.Header.Get("X-Results-Truncated"))
+
+	res, err = http.Get(server.URL + "/withinBoundsJson?datasource=newyork&limit=1&" + bounds)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&records))
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "true", res.Header.Get("X-Results-Truncated"))
+
+	// Upside down boxes are rejected
+	res, err = http.Get(server.URL + "/withinBoundsJson?datasource=newyork&southWestLatitude=45&southWestLongitude=-79.8&northEastLatitude=40.5&northEastLongitude=-71.8")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestEnvelopeOffline(t *testing.T) {
+	server := newTestService(t)
+
+	// Fewer markets than requested is reported, not an error
+	var envelope Envelope
+	getJson(t, server, "/v1/nearestN?n=3&latitude=40.78&longitude=-73.95&datasource=usda&units=km", &envelope)
+
+	require.Equal(t, EnvelopeVersion, envelope.Version)
+	require.Equal(t, 3, envelope.Requested)
+	require.Equal(t, 1, envelope.Returned)
+	require.Equal(t, "Potsdam Farmers Market", envelope.Results[0].Name)
+	require.Equal(t, "usda", envelope.Datasource)
+	require.Equal(t, geography.Kilometers, envelope.Units)
+	require.Equal(t, []string{"found 1 of the 3 markets requested within 500 miles"}, envelope.Warnings)
+	require.NotNil(t, envelope.DataAsOf)
+	require.False(t, envelope.GeneratedAt.Before(*envelope.DataAsOf))
+
+	// The datasource chosen by the auto datasource is reported
+	envelope = Envelope{}
+	getJson(t, server, "/v1/nearestNByZipCode?n=1&zipCode=12207&datasource=auto", &envelope)
+	require.Equal(t, "newyork", envelope.Datasource)
+	require.Equal(t, geography.Meters, envelope.Units)
+	require.Equal(t, []string{}, envelope.Warnings)
+	require.NotNil(t, envelope.DataAsOf)
+
+	envelope = Envelope{}
+	getJson(t, server, "/v1/withinRadius?distance=1&units=mi&latitude=42.65&longitude=-73.75&datasource=newyork", &envelope)
+	require.Equal(t, -1, envelope.Requested)
+	require.Equal(t, 1, envelope.Returned)
+
+	envelope = Envelope{}
+	getJson(t, server, "/v1/withinBounds?datasource=newyork&limit=1&southWestLatitude=40.5&southWestLongitude=-79.8&northEastLatitude=45&northEastLongitude=-71.8", &envelope)
+	require.Equal(t, 1, envelope.Requested)
+	require.Equal(t, 1, envelope.Returned)
+	require.Equal(t, 1, len(envelope.Warnings))
+
+	// Bad input is still rejected with a status
+	res, err := http.Get(server.URL + "/v1/nearestN?n=three&latitude=40.78&longitude=-73.95&datasource=usda")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestNearestNJsonFilters(t *testing.T) {
+	server := newTestService(t)
+
+	// Albany is nearer, but only the 82nd Street Greenmarket is part of the FMNP
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource=newyork&fmnp=true", &records)
+
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+
+	// Seasons are given by code or by name, year-round markets operate in every season
+	getJson(t, server, "/nearestNJson?n=-1&latitude=42.65&longitude=-73.75&datasource=newyork&season=winter", &records)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+
+	getJson(t, server, "/nearestNJson?n=-1&latitude=42.65&longitude=-73.75&datasource=newyork&season=P", &records)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, api.NewSeasonSet(api.Spring, api.Summer), records[0].Seasons)
+
+	for _, path := range []string{
+		// The USDA datasource has no SNAP data
+		"/nearestNJsonByZipCode?n=1&zipCode=10023&datasource=usda&snap=true",
+		"/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource=newyork&season=Q",
+		"/nearestNJson?n=1&latitude=42.65&longitude=-73.75&datasource=newyork&snap=maybe",
+	} {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+	}
+}
+
+func TestNearestNJsonOpenAt(t *testing.T) {
+	server := newTestService(t)
+
+	// Albany is open on Sundays from 10am to 2pm, and the 82nd Street Greenmarket
+	// on Saturdays from 9am to 2:30pm. 2024-05-05 is a Sunday.
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=-1&latitude=40.78&longitude=-73.95&datasource=newyork&openAt=2024-05-05T11:00:00-04:00", &records)
+
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "Albany County Farmers' Market", records[0].Name)
+	require.Equal(t, api.WeeklySchedule{{Day: api.Weekday(time.Sunday), Opens: 10 * 60, Closes: 14 * 60}}, records[0].OperationSchedule)
+
+	getJson(t, server, "/nearestNJson?n=-1&latitude=40.78&longitude=-73.95&datasource=newyork&openAt=2024-05-04T14:15:00-04:00", &records)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+
+	for _, path := range []string{
+		"/nearestNJson?n=-1&latitude=40.78&longitude=-73.95&datasource=newyork&openAt=tomorrow",
+		"/nearestNJson?n=-1&latitude=40.78&longitude=-73.95&datasource=newyork&openAt=2024-05-04T14:15:00-04:00&openNow=true",
+		"/nearestNJson?n=1&latitude=40.78&longitude=-73.95&datasource=usda&openNow=true",
+	} {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+	}
+}
+
+func TestNearestNJsonInSeasonOn(t *testing.T) {
+	server := newTestService(t)
+
+	var records []api.FarmersMarketRecord
+	getJson(t, server, "/nearestNJson?n=-1&latitude=42.65&longitude=-73.75&datasource=newyork&inSeasonOn=2025-01-15", &records)
+
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "82nd Street Greenmarket", records[0].Name)
+
+	getJson(t, server, "/nearestNJson?n=-1&latitude=42.65&longitude=-73.75&datasource=newyork&inSeasonOn=2025-07-04", &records)
+
+	require.Equal(t, 2, len(records))
+	require.Equal(t, &api.MonthDay{Month: time.May, Day: 4}, records[0].SeasonStart)
+	require.Equal(t, &api.MonthDay{Month: time.November, Day: 23}, records[0].SeasonEnd)
+
+	res, err := http.Get(server.URL + "/nearestNJson?n=-1&latitude=42.65&longitude=-73.75&datasource=newyork&inSeasonOn=January")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
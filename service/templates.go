@@ -2,13 +2,15 @@ package service
 
 import (
 	"fmt"
+	"html/template"
 
 	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
 )
 
 type GenericPageTemplateData struct {
-	HtmlHead       string
-	HeadingAndMenu string
+	HtmlHead       template.HTML
+	HeadingAndMenu template.HTML
 }
 
 var defaultGenericPageTemplateData GenericPageTemplateData
@@ -24,16 +26,42 @@ func (data *GenericPageTemplateData) load() error {
 	return nil
 }
 
-type NearestNTemplateData struct {
+type GetLocationTemplateData struct {
 	GenericPageTemplateData // embedded struct
-	Count                   int
+	Datasources             []api.Datasource
+	DefaultDatasource       string
+}
+
+// NewGetLocationTemplateData returns a GetLocationTemplateData struct
+// pre-populated with the HtmlHead and the HeadingAndMenu. It
+// returns an error if the templates have not been loaded yet.
+func NewGetLocationTemplateData(datasources []api.Datasource, defaultDatasource string) (GetLocationTemplateData, error) {
+	returnData := GetLocationTemplateData{}
+
+	if !defaultView.loaded {
+		return returnData, fmt.Errorf("templates not loaded")
+	}
+
+	returnData.HtmlHead = defaultView.head
+	returnData.HeadingAndMenu = defaultView.headingAndMenu
+	returnData.Datasources = datasources
+	returnData.DefaultDatasource = defaultDatasource
+
+	return returnData, nil
+}
+
+type NearestNTemplateData struct {
+	GenericPageTemplateData     // embedded struct
+	ResponseMetadata            // embedded struct
+	Count                   int // the number of markets requested, -1 for all of them
 	Records                 []api.FarmersMarketRecord
+	Units                   geography.DistanceUnit // of the distances of the records
 }
 
 // NewNearestTemplateData returns a NearestNTemplateData struct
 // pre-populated with the HtmlHead and the HeadingAndMenu. It
 // returns an error if the templates have not been loaded yet.
-func NewNearestNTemplateData(count int, records []api.FarmersMarketRecord) (NearestNTemplateData, error) {
+func NewNearestNTemplateData(count int, records []api.FarmersMarketRecord, units geography.DistanceUnit, metadata ResponseMetadata) (NearestNTemplateData, error) {
 	returnData := NearestNTemplateData{}
 
 	if !defaultView.loaded {
@@ -44,13 +72,39 @@ func NewNearestNTemplateData(count int, records []api.FarmersMarketRecord) (Near
 	returnData.HeadingAndMenu = defaultView.headingAndMenu
 	returnData.Count = count
 	returnData.Records = records
+	returnData.Units = units
+	returnData.ResponseMetadata = metadata
 
 	return returnData, nil
 }
 
-type DeveloperResourcesTemplateData struct {
+type MarketTemplateData struct {
 	GenericPageTemplateData // embedded struct
-	MarkdownHTML            string
+	Record                  api.FarmersMarketRecord
+	Datasource              api.Datasource
+}
+
+// NewMarketTemplateData returns a MarketTemplateData struct
+// pre-populated with the HtmlHead and the HeadingAndMenu. It
+// returns an error if the templates have not been loaded yet.
+func NewMarketTemplateData(record api.FarmersMarketRecord, datasource api.Datasource) (MarketTemplateData, error) {
+	returnData := MarketTemplateData{}
+
+	if !defaultView.loaded {
+		return returnData, fmt.Errorf("templates not loaded")
+	}
+
+	returnData.HtmlHead = defaultView.head
+	returnData.HeadingAndMenu = defaultView.headingAndMenu
+	returnData.Record = record
+	returnData.Datasource = datasource
+
+	return returnData, nil
+}
+
+type DeveloperResourcesTemplateData struct {
+	GenericPageTemplateData               // embedded struct
+	MarkdownHTML            template.HTML // rendered from our own documentation, so shown as is
 }
 
 // NewDeveloperResourcesTemplateData returns a DeveloperResourceTemplateData
@@ -66,7 +120,7 @@ func NewDeveloperResourcesTemplateData(markdownHtml string) (DeveloperResourcesT
 
 	returnData.HtmlHead = defaultView.head
 	returnData.HeadingAndMenu = defaultView.headingAndMenu
-	returnData.MarkdownHTML = markdownHtml
+	returnData.MarkdownHTML = template.HTML(markdownHtml)
 
 	return returnData, nil
 }
@@ -2,7 +2,10 @@ package service
 
 import (
 	"embed"
-	"text/template"
+	"html/template"
+	"net/url"
+
+	"github.com/jadidbourbaki/gofarm/api"
 )
 
 //go:embed templates/*.html
@@ -13,10 +16,13 @@ type View struct {
 	// from embed FS
 	loaded bool
 
-	head           string
-	headingAndMenu string
+	// head and headingAndMenu are our own HTML, shown as is on every page. Everything
+	// else the templates show, such as the listings of the datasources, is escaped.
+	head           template.HTML
+	headingAndMenu template.HTML
 
 	nearestN           *template.Template
+	market             *template.Template
 	getLocation        *template.Template
 	supportUs          *template.Template
 	developerResources *template.Template
@@ -34,6 +40,32 @@ func loadDefaultViewAndTemplates() error {
 	return nil
 }
 
+// templateFuncs are the functions available to the templates
+var templateFuncs = template.FuncMap{
+	"marketPath": marketPath,
+}
+
+// marketPath returns the path of the detail page of the market with the given ID
+func marketPath(id string) string {
+	datasource, localID, err := api.SplitMarketID(id)
+	if err != nil {
+		return ""
+	}
+
+	return "/markets/" + url.PathEscape(datasource) + "/" + url.PathEscape(localID) + "/html"
+}
+
+// parseWithRecord parses a template that shows markets with the "record" template
+// defined in record.html
+func parseWithRecord(name string, text string, recordText string) (*template.Template, error) {
+	parsed, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Parse(recordText)
+}
+
 // load reads and parses all the templates and other files for the view
 func (view *View) load() error {
 	headBytes, err := templateFS.ReadFile("templates/head.html")
@@ -42,7 +74,7 @@ func (view *View) load() error {
 		return err
 	}
 
-	view.head = string(headBytes)
+	view.head = template.HTML(headBytes)
 
 	headingAndMenuBytes, err := templateFS.ReadFile("templates/headingAndMenu.html")
 
@@ -50,7 +82,13 @@ func (view *View) load() error {
 		return err
 	}
 
-	view.headingAndMenu = string(headingAndMenuBytes)
+	view.headingAndMenu = template.HTML(headingAndMenuBytes)
+
+	recordBytes, err := templateFS.ReadFile("templates/record.html")
+
+	if err != nil {
+		return err
+	}
 
 	nearestNBytes, err := templateFS.ReadFile("templates/nearestN.html")
 
@@ -58,7 +96,7 @@ func (view *View) load() error {
 		return err
 	}
 
-	nearestNTemplate, err := template.New("nearestN").Parse(string(nearestNBytes))
+	nearestNTemplate, err := parseWithRecord("nearestN", string(nearestNBytes), string(recordBytes))
 
 	if err != nil {
 		return err
@@ -66,6 +104,20 @@ func (view *View) load() error {
 
 	view.nearestN = nearestNTemplate
 
+	marketBytes, err := templateFS.ReadFile("templates/market.html")
+
+	if err != nil {
+		return err
+	}
+
+	marketTemplate, err := parseWithRecord("market", string(marketBytes), string(recordBytes))
+
+	if err != nil {
+		return err
+	}
+
+	view.market = marketTemplate
+
 	getLocationBytes, err := templateFS.ReadFile("templates/getLocation.html")
 
 	if err != nil {
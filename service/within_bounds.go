@@ -0,0 +1,142 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// pointFromQuery returns the point given by the latitude and longitude query parameters
+// with the given prefix, e.g. southWestLatitude and southWestLongitude
+func pointFromQuery(query url.Values, prefix string) (geography.HaversinePoint, error) {
+	latitude, err := strconv.ParseFloat(query.Get(prefix+"Latitude"), 64)
+	if err != nil {
+		return geography.HaversinePoint{}, fmt.Errorf("incorrect value for %sLatitude: %w", prefix, err)
+	}
+
+	longitude, err := strconv.ParseFloat(query.Get(prefix+"Longitude"), 64)
+	if err != nil {
+		return geography.HaversinePoint{}, fmt.Errorf("incorrect value for %sLongitude: %w", prefix, err)
+	}
+
+	return geography.HaversinePoint{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// withinBoundsInternal returns the Farmers' Markets inside a bounding box, such as the viewport
+// of a map, as its first return value and true as its second return value if we were able to get
+// them. Otherwise, it returns a false as its second return value. At most Config.WithinBoundsLimit
+// markets are returned, fewer if the limit query parameter asks for fewer. If there were more
+// markets in the box, the X-Results-Truncated header is set to true and a warning is added.
+func (service *Service) withinBoundsInternal(w http.ResponseWriter, r *http.Request, defaultUnit geography.DistanceUnit) (*NearestNTemplateData, bool) {
+	query := r.URL.Query()
+
+	southWest, err := pointFromQuery(query, "southWest")
+	if err != nil {
+		service.sugaredLogger.Errorf("withinBounds: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	northEast, err := pointFromQuery(query, "northEast")
+	if err != nil {
+		service.sugaredLogger.Errorf("withinBounds: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	if _, err := geography.NewBoundingBox(southWest, northEast); err != nil {
+		service.sugaredLogger.Errorf("incorrect bounds: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	limit := service.config.WithinBoundsLimit
+	if limitString := query.Get("limit"); limitString != "" {
+		requestedLimit, err := strconv.Atoi(limitString)
+		if err != nil || requestedLimit < 0 {
+			service.sugaredLogger.Errorf("incorrect value for limit: %s", limitString)
+			w.WriteHeader(http.StatusBadRequest)
+			return nil, false
+		}
+
+		limit = min(limit, requestedLimit)
+	}
+
+	unit, err := distanceUnitFromQuery(query, defaultUnit)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for units: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	datasourceString := query.Get("datasource")
+	datasourceApi, ok := service.ApiForDataSource(datasourceString)
+
+	if !ok {
+		service.sugaredLogger.Errorf("could not find api for datasource: %s", datasourceString)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	ctx, cancel := service.upstreamContext(r, datasourceString)
+	defer cancel()
+
+	// Ask for one more than we return to find out whether there were more
+	records, err := datasourceApi.WithinBounds(ctx, southWest, northEast, limit+1)
+	if err != nil {
+		service.sugaredLogger.Errorf("withinBounds: %v", err)
+		writeUpstreamError(w, err)
+		return nil, false
+	}
+
+	truncated := len(records) > limit
+	if truncated {
+		records = records[:limit]
+	}
+
+	api.ConvertDistances(records, unit)
+
+	metadata := writeQueryReport(ctx, w, datasourceString)
+	if truncated {
+		warning := fmt.Sprintf("the box has more than %d markets, returned the ones nearest to its center", limit)
+		metadata.Warnings = append(metadata.Warnings, warning)
+		w.Header().Add(warningHeader, warning)
+	}
+
+	w.Header().Set("X-Results-Truncated", strconv.FormatBool(truncated))
+
+	data, err := NewNearestNTemplateData(limit, records, unit, metadata)
+	if err != nil {
+		service.sugaredLogger.Errorf("loading nearestN template data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &data, true
+}
+
+// withinBoundsJsonHandler returns the Farmers' Markets inside a bounding box in JSON format,
+// see withinBoundsInternal
+func (service *Service) withinBoundsJsonHandler(w http.ResponseWriter, r *http.Request) {
+	data, ok := service.withinBoundsInternal(w, r, geography.Meters)
+
+	if !ok {
+		return
+	}
+
+	recordsJson, err := json.Marshal(data.Records)
+	if err != nil {
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(recordsJson)
+}
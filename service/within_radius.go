@@ -0,0 +1,119 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jadidbourbaki/gofarm/api"
+	"github.com/jadidbourbaki/gofarm/geography"
+)
+
+// withinRadiusInternal returns the records as its first return value and true as its second return value if
+// we were able to get the records within the radius. Otherwise, it returns a false as its second return value.
+// The distance query parameter is in the unit requested with the units query parameter, or defaultUnit,
+// and so are the distances of the records.
+func (service *Service) withinRadiusInternal(w http.ResponseWriter, r *http.Request, defaultUnit geography.DistanceUnit) (*NearestNTemplateData, bool) {
+	query := r.URL.Query()
+
+	latitudeString := query.Get("latitude")
+	latitude, err := strconv.ParseFloat(latitudeString, 64)
+
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for latitude: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	longitudeString := query.Get("longitude")
+	longitude, err := strconv.ParseFloat(longitudeString, 64)
+
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for longitude: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	unit, err := distanceUnitFromQuery(query, defaultUnit)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for units: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	distance, err := distanceFromQuery(query, unit)
+	if err != nil {
+		service.sugaredLogger.Errorf("incorrect value for distance: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	datasourceString := query.Get("datasource")
+	datasourceApi, ok := service.ApiForDataSource(datasourceString)
+
+	if !ok {
+		service.sugaredLogger.Errorf("could not find api for datasource: %s", datasourceString)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	ctx, cancel := service.upstreamContext(r, datasourceString)
+	defer cancel()
+
+	point := geography.HaversinePoint{Latitude: latitude, Longitude: longitude}
+	records, err := datasourceApi.WithinRadius(ctx, point, distance)
+
+	if err != nil {
+		service.sugaredLogger.Errorf("withinRadius: %v", err)
+		writeUpstreamError(w, err)
+		return nil, false
+	}
+
+	api.ConvertDistances(records, unit)
+
+	metadata := writeQueryReport(ctx, w, datasourceString)
+
+	data, err := NewNearestNTemplateData(-1, records, unit, metadata)
+	if err != nil {
+		service.sugaredLogger.Errorf("loading nearestN template data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &data, true
+}
+
+// withinRadiusJsonHandler returns the Farmers' Markets within a radius of a location in JSON format
+func (service *Service) withinRadiusJsonHandler(w http.ResponseWriter, r *http.Request) {
+	data, ok := service.withinRadiusInternal(w, r, geography.Meters)
+
+	if !ok {
+		return
+	}
+
+	recordsJson, err := json.Marshal(data.Records)
+	if err != nil {
+		service.sugaredLogger.Errorf("marshalling json: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(recordsJson)
+}
+
+// withinRadiusHTMLHandler returns the Farmers' Markets within a radius of a location in HTML format
+func (service *Service) withinRadiusHTMLHandler(w http.ResponseWriter, r *http.Request) {
+	data, ok := service.withinRadiusInternal(w, r, geography.Miles)
+
+	if !ok {
+		return
+	}
+
+	err := defaultView.nearestN.Execute(w, data)
+	if err != nil {
+		service.sugaredLogger.Errorf("executing template: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
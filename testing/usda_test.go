@@ -1,6 +1,7 @@
 package testing
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -18,7 +19,7 @@ func TestFetchingUSDAData(t *testing.T) {
 	require.Nil(t, err)
 
 	// Just a random location to test
-	_, err = api.FetchUSDADataByLocation(houstonLocation)
+	_, err = api.FetchUSDADataByLocation(context.Background(), houstonLocation)
 	require.Nil(t, err)
 }
 
@@ -27,7 +28,7 @@ func TestMarshallingUSDAData(t *testing.T) {
 	err := api.DefaultCredentials.LoadUSDACredentials()
 	require.Nil(t, err)
 
-	data, err := api.FetchUSDADataByLocation(houstonLocation)
+	data, err := api.FetchUSDADataByLocation(context.Background(), houstonLocation)
 	require.Nil(t, err)
 
 	var records map[string]interface{}
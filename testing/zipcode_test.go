@@ -1,6 +1,7 @@
 package testing
 
 import (
+	"context"
 	"testing"
 
 	"github.com/jadidbourbaki/gofarm/api"
@@ -13,7 +14,7 @@ func TestZipCodeToHaversinePoint(t *testing.T) {
 	require.Nil(t, err)
 
 	randomZipCode := "77001"
-	point, err := api.ZipCodeToHaversinePoint(randomZipCode)
+	point, err := api.ZipCodeToHaversinePoint(context.Background(), randomZipCode)
 
 	require.Nil(t, err)
 